@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"errors"
+	"io"
+	"log/syslog"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSinkClosed is returned when writing to a closed AsyncSink.
+var ErrSinkClosed = errors.New("sink closed")
+
+// OverflowPolicy decides what an AsyncSink does with a record when its queue
+// is full.
+type OverflowPolicy struct {
+	drop  overflowDrop
+	level syslog.Priority
+}
+
+type overflowDrop uint8
+
+const (
+	dropNone overflowDrop = iota
+	dropNewest
+	dropOldest
+	dropBelow
+)
+
+var (
+	// OverflowBlock blocks the caller until there is room in the queue.
+	OverflowBlock = OverflowPolicy{drop: dropNone}
+	// OverflowDropNewest drops the record being written.
+	OverflowDropNewest = OverflowPolicy{drop: dropNewest}
+	// OverflowDropOldest drops the oldest queued record to make room.
+	OverflowDropOldest = OverflowPolicy{drop: dropOldest}
+)
+
+// OverflowDropBelow drops the record being written if it is less severe than
+// p and otherwise blocks the caller until there is room in the queue, so that
+// e.g. errors are never lost to a flood of debug logs.
+func OverflowDropBelow(p syslog.Priority) OverflowPolicy {
+	return OverflowPolicy{drop: dropBelow, level: p & severityMask}
+}
+
+// AsyncSink is a Sink queueing records to be written to another sink by a
+// background goroutine, so that a slow sink does not block logging. The queue
+// is bounded and records are handled by an OverflowPolicy when it is full.
+//
+// Errors writing to the wrapped sink are returned by a later call to
+// WriteRecord. Close writes any queued records before closing the wrapped
+// sink if it implements io.Closer.
+type AsyncSink struct {
+	dropped uint64 // accessed atomically, first for alignment
+
+	sink   Sink
+	policy OverflowPolicy
+	size   int
+
+	queue chan *Record
+	quit  chan struct{} // closed to release blocked writers on Close
+	done  chan struct{} // closed when the queue has been drained
+
+	mu      sync.RWMutex // write lock held to close the queue
+	closed  bool
+	closeMu sync.Mutex // serializes Close
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewAsyncSink returns a sink writing records to s from a queue of size
+// records, handling records that do not fit by policy. A size less than one
+// results in a queue of one record.
+func NewAsyncSink(s Sink, size int, policy OverflowPolicy) *AsyncSink {
+	if size < 1 {
+		size = 1
+	}
+	a := &AsyncSink{
+		sink:   s,
+		policy: policy,
+		size:   size,
+		queue:  make(chan *Record, size),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncSink) run() {
+	defer close(a.done)
+	for r := range a.queue {
+		if err := a.sink.WriteRecord(r); err != nil {
+			a.errMu.Lock()
+			a.err = err
+			a.errMu.Unlock()
+		}
+	}
+}
+
+// WriteRecord implements Sink by queueing a copy of r.
+func (a *AsyncSink) WriteRecord(r *Record) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrSinkClosed
+	}
+
+	rec := *r
+	if !a.enqueue(&rec) {
+		atomic.AddUint64(&a.dropped, 1)
+	}
+
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// enqueue adds r to the queue, following the overflow policy if it is full,
+// and reports whether r was queued.
+func (a *AsyncSink) enqueue(r *Record) bool {
+	select {
+	case a.queue <- r:
+		return true
+	default:
+	}
+
+	switch a.policy.drop {
+	case dropNewest:
+		return false
+	case dropOldest:
+		for {
+			select {
+			case a.queue <- r:
+				return true
+			default:
+			}
+			select {
+			case <-a.queue:
+				atomic.AddUint64(&a.dropped, 1)
+			default:
+			}
+		}
+	case dropBelow:
+		if (r.Priority & severityMask) > a.policy.level {
+			return false
+		}
+	}
+
+	select {
+	case a.queue <- r:
+		return true
+	case <-a.quit:
+		return false
+	}
+}
+
+// Reload implements Reloader by reloading the wrapped sink, if it implements
+// Reloader.
+func (a *AsyncSink) Reload() error {
+	if r, ok := a.sink.(Reloader); ok {
+		return r.Reload()
+	}
+	return nil
+}
+
+// Dropped returns the number of records dropped because the queue was full
+// or the sink was closed while waiting for room in the queue.
+func (a *AsyncSink) Dropped() uint64 { return atomic.LoadUint64(&a.dropped) }
+
+// Close writes any queued records and closes the wrapped sink if it
+// implements io.Closer. Writers blocked waiting for room in the queue have
+// their records dropped.
+func (a *AsyncSink) Close() error {
+	a.closeMu.Lock()
+	defer a.closeMu.Unlock()
+	select {
+	case <-a.quit:
+		return ErrSinkClosed
+	default:
+	}
+
+	close(a.quit)
+	a.mu.Lock()
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+	<-a.done
+
+	if c, ok := a.sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log/syslog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+// gateSink records messages, blocking each write until the gate is opened.
+type gateSink struct {
+	gate    chan struct{}
+	started chan struct{} // receives when a write starts waiting on the gate
+	err     error
+
+	mu   sync.Mutex
+	msgs []string
+}
+
+func newGateSink() *gateSink {
+	return &gateSink{gate: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (s *gateSink) WriteRecord(r *log.Record) error {
+	s.started <- struct{}{}
+	<-s.gate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, r.Message)
+	return s.err
+}
+
+func (s *gateSink) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+// fill writes msgs to a, waiting for the first to be taken off the queue so
+// that the rest fill it.
+func fill(t *testing.T, a *log.AsyncSink, s *gateSink, msgs ...string) {
+	for i, msg := range msgs {
+		if err := a.WriteRecord(&log.Record{Priority: syslog.LOG_INFO, Message: msg}); err != nil {
+			t.Fatalf("error writing record: %v", err)
+		}
+		if i == 0 {
+			<-s.started
+		}
+	}
+}
+
+func TestAsyncSinkOverflow(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  log.OverflowPolicy
+		expect  []string
+		dropped uint64
+	}{
+		{"drop newest", log.OverflowDropNewest, []string{"1", "2", "3"}, 1},
+		{"drop oldest", log.OverflowDropOldest, []string{"1", "3", "4"}, 1},
+		{"drop below", log.OverflowDropBelow(syslog.LOG_WARNING), []string{"1", "2", "3"}, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newGateSink()
+			a := log.NewAsyncSink(s, 2, tt.policy)
+			fill(t, a, s, "1", "2", "3", "4")
+			if dropped := a.Dropped(); dropped != tt.dropped {
+				t.Errorf("expected %d dropped records, got %d", tt.dropped, dropped)
+			}
+
+			close(s.gate)
+			if err := a.Close(); err != nil {
+				t.Fatalf("error closing sink: %v", err)
+			}
+			if msgs := s.messages(); !reflect.DeepEqual(msgs, tt.expect) {
+				t.Errorf("expected messages %v, got %v", tt.expect, msgs)
+			}
+		})
+	}
+}
+
+func TestAsyncSinkBlock(t *testing.T) {
+	s := newGateSink()
+	a := log.NewAsyncSink(s, 1, log.OverflowDropBelow(syslog.LOG_WARNING))
+	fill(t, a, s, "1", "2")
+
+	// Expect a WARNING to block until there is room in the queue
+	written := make(chan struct{})
+	go func() {
+		_ = a.WriteRecord(&log.Record{Priority: syslog.LOG_WARNING, Message: "3"})
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("expected write to block while queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(s.gate)
+	<-written
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("error closing sink: %v", err)
+	}
+	if msgs := s.messages(); !reflect.DeepEqual(msgs, []string{"1", "2", "3"}) {
+		t.Errorf("expected all messages to be written, got %v", msgs)
+	}
+	if a.Dropped() != 0 {
+		t.Errorf("expected no dropped records, got %d", a.Dropped())
+	}
+	if err := a.WriteRecord(&log.Record{Message: "4"}); err != log.ErrSinkClosed {
+		t.Errorf("expected ErrSinkClosed writing to closed sink, got %v", err)
+	}
+}
+
+func TestAsyncSinkError(t *testing.T) {
+	s := newGateSink()
+	s.err = errors.New("unreachable")
+	close(s.gate)
+	a := log.NewAsyncSink(s, 1, log.OverflowBlock)
+	defer a.Close()
+
+	// Expect the error of a write to be returned by the next one
+	_ = a.WriteRecord(&log.Record{Message: "1"})
+	<-s.started
+	for i := 0; i < 100; i++ {
+		if err := a.WriteRecord(&log.Record{Message: "2"}); err != nil {
+			if err != s.err {
+				t.Errorf("expected %v, got %v", s.err, err)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("expected error of wrapped sink to be returned")
+}
+
+func TestLoggerSyslogAsync(t *testing.T) {
+	conn := listenSyslog(t)
+	defer conn.Close()
+
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslog(conn.LocalAddr().String(),
+		log.SyslogAsync(10, log.OverflowDropNewest)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+
+	logger.Info("hello")
+	if err := logger.DisconnectSyslog(); err != nil {
+		t.Fatalf("error disconnecting from syslog: %v", err)
+	}
+	if msg := readSyslog(t, conn); !strings.HasSuffix(msg, "hello\n") {
+		t.Errorf("expected %q to end with 'hello\\n'", msg)
+	}
+	if dropped := logger.SyslogDropped(); dropped != 0 {
+		t.Errorf("expected no dropped logs, got %d", dropped)
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"log/syslog"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Keys of the fields tagging logs with the location they were printed at.
+const (
+	// CallerKey is the key of the field holding the file and line, e.g.
+	// "proxy/preface.go:42".
+	CallerKey = "caller"
+	// FuncKey is the key of the field holding the function, e.g.
+	// "proxy.(*PrefaceListener).storeConn".
+	FuncKey = "func"
+	// StackKey is the key of the field holding the stack of the goroutine.
+	StackKey = "stack"
+)
+
+// NoStack is the stack level of a Logger not capturing stacks, see
+// SetStackLevel.
+const NoStack syslog.Priority = -1
+
+// maxStackDepth is the number of frames captured in a stack.
+const maxStackDepth = 64
+
+// callerSkip are the prefixes of the functions skipped to find the caller: the
+// funcs of this package, including the package level wrappers and
+// GrpcLogger, of grpclog, which calls GrpcLogger, and of the standard log and
+// log/slog packages, which call LineWriter and SlogHandler.
+var callerSkip = []string{
+	thisPackage() + ".",
+	"google.golang.org/grpc/grpclog.",
+	"google.golang.org/grpc/internal/grpclog.",
+	"log.",
+	"log/slog.",
+}
+
+// SetCaller tags each log with the file and line and the function it was
+// printed at, in fields with the keys CallerKey and FuncKey, or stops tagging
+// them. Funcs of this package, e.g. the package level funcs and GrpcLogger,
+// are skipped to find the caller.
+func (l *Logger) SetCaller(enabled bool) {
+	l.once.Do(l.initPrinter)
+
+	l.callerMu.Lock()
+	defer l.callerMu.Unlock()
+	l.caller = enabled
+}
+
+// SetStackLevel tags logs at and above severity p with the stack of the
+// goroutine printing them, in a field with the key StackKey. It takes values
+// syslog.LOG_EMERG...syslog.LOG_DEBUG or NoStack, which is the default.
+func (l *Logger) SetStackLevel(p syslog.Priority) {
+	l.once.Do(l.initPrinter)
+
+	l.callerMu.Lock()
+	defer l.callerMu.Unlock()
+	if p < 0 {
+		p = NoStack
+	} else {
+		p &= severityMask
+	}
+	l.stackLevel, l.stackSet = p, true
+}
+
+// GetStackLevel returns the severity at and above which logs are tagged with
+// the stack of the goroutine printing them.
+func (l *Logger) GetStackLevel() syslog.Priority {
+	l.callerMu.RLock()
+	defer l.callerMu.RUnlock()
+	if !l.stackSet {
+		return NoStack
+	}
+	return l.stackLevel
+}
+
+// callerFields returns the fields tagging a log with severity p with its
+// caller and stack, if enabled. The caller is the first frame not skipped by
+// callerSkip, or depth frames above it for funcs logging on behalf of their
+// callers, like those calling the Depth methods of GrpcLogger.
+func (l *Logger) callerFields(p syslog.Priority, depth int) []Field {
+	l.callerMu.RLock()
+	caller := l.caller
+	stack := l.stackSet && (p&severityMask) <= l.stackLevel
+	l.callerMu.RUnlock()
+	if !caller && !stack {
+		return nil
+	}
+
+	var pcs [maxStackDepth]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	var (
+		fields []Field
+		buf    []byte
+		found  bool
+	)
+	for {
+		frame, more := frames.Next()
+		if !found && !skipFrame(frame.Function) {
+			depth--
+		}
+		if !found && depth < 0 {
+			found = true
+			if caller {
+				fields = append(fields,
+					String(CallerKey, shortFile(frame.File)+":"+strconv.Itoa(frame.Line)),
+					String(FuncKey, shortFunc(frame.Function)))
+			}
+		}
+		if found && stack {
+			buf = append(buf, frame.Function...)
+			buf = append(buf, "\n\t"...)
+			buf = append(buf, frame.File...)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+			buf = append(buf, '\n')
+		}
+		if !more {
+			break
+		}
+	}
+	if stack {
+		fields = append(fields, String(StackKey, strings.TrimSuffix(string(buf), "\n")))
+	}
+	return fields
+}
+
+func skipFrame(function string) bool {
+	for _, prefix := range callerSkip {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// shortFile returns the base name of a file and its directory.
+func shortFile(file string) string {
+	dir, base := path.Split(file)
+	return path.Join(path.Base(dir), base)
+}
+
+// shortFunc returns the name of a function without the path of its package.
+func shortFunc(function string) string {
+	return function[strings.LastIndex(function, "/")+1:]
+}
+
+// thisPackage returns the import path of this package.
+func thisPackage() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+1+strings.Index(name[slash+1:], ".")]
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/open-ness/common/log"
+)
+
+// line returns the line of its caller.
+func line() int {
+	_, _, n, _ := runtime.Caller(1)
+	return n
+}
+
+func TestLoggerCaller(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetCaller(true)
+	grpcLogger := &log.GrpcLogger{Logger: logger}
+
+	// Expect the caller to be found through each chain of funcs
+	for _, print := range []func() int{
+		func() int { logger.Info("msg"); return line() },
+		func() int { logger.Println(syslog.LOG_INFO, "msg"); return line() },
+		func() int { logger.Component("proxy").Warningf("%s", "msg"); return line() },
+		func() int { grpcLogger.Errorln("msg"); return line() },
+		func() int { grpcLogger.Errorf("%s", "msg"); return line() },
+	} {
+		buf.Reset()
+		n := print()
+		expect := fmt.Sprintf("[caller=log/caller_test.go:%d] [func=log_test.TestLoggerCaller.func", n)
+		if !strings.Contains(buf.String(), expect) || strings.Contains(buf.String(), "[stack=") {
+			t.Errorf("expected %q in output %q", expect, buf.String())
+		}
+	}
+
+	// Expect a stack at and above the stack level only
+	logger.SetCaller(false)
+	logger.SetStackLevel(syslog.LOG_ERR)
+	if lvl := logger.GetStackLevel(); lvl != syslog.LOG_ERR {
+		t.Errorf("expected stack level %v, got %v", syslog.LOG_ERR, lvl)
+	}
+	buf.Reset()
+	logger.Warning("msg")
+	if strings.Contains(buf.String(), "[caller=") || strings.Contains(buf.String(), "[stack=") {
+		t.Errorf("expected no caller or stack in output %q", buf.String())
+	}
+	buf.Reset()
+	logger.Crit("msg")
+	if !strings.Contains(buf.String(), "[stack=github.com/open-ness/common/log_test.TestLoggerCaller\n\t") {
+		t.Errorf("expected stack in output %q", buf.String())
+	}
+}
+
+func TestDefaultLoggerCaller(t *testing.T) {
+	defer func() { log.DefaultLogger = new(log.Logger) }()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.DefaultLogger.SetCaller(true)
+
+	log.Infof("%s", "msg")
+	expect := fmt.Sprintf("[caller=log/caller_test.go:%d]", line()-1)
+	if !strings.Contains(buf.String(), expect) {
+		t.Errorf("expected %q in output %q", expect, buf.String())
+	}
+}
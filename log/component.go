@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// ComponentKey is the key of the field tagging logs of a component Printer.
+const ComponentKey = "component"
+
+// Component returns a Printer for a named component, tagged with a field
+// holding the name. Components are hierarchical with levels of the hierarchy
+// separated by dots, e.g. "proxy.preface" is a child of "proxy". The level of
+// a component can be set with SetComponentLevel or SetLevelSpec and is
+// otherwise inherited from its parent and ultimately from the Logger.
+func (l *Logger) Component(name string) Printer {
+	return l.printer(name, []Field{String(ComponentKey, name)})
+}
+
+// Component returns a Printer for a child component of p, or a top level
+// component if p has none, tagged with the fields of p. See
+// (*Logger).Component.
+func (p Printer) Component(name string) Printer {
+	l := p.logger
+	if l == nil {
+		l = DefaultLogger
+	}
+	if p.component != "" {
+		name = p.component + "." + name
+	}
+	return l.printer(name, mergeFields(p.fields, []Field{String(ComponentKey, name)}))
+}
+
+// SetComponentLevel sets the verbosity level of a component and, unless they
+// have their own level, its children. The pattern may also end in ".*" to
+// only set the level of the children of a component, e.g. "proxy.*". A
+// pattern of "*" is equivalent to SetLevel.
+func (l *Logger) SetComponentLevel(pattern string, p syslog.Priority) {
+	l.once.Do(l.initPrinter)
+
+	l.priorityMu.Lock()
+	defer l.priorityMu.Unlock()
+	l.setComponentLevel(pattern, p)
+}
+
+// Only call with a write lock on the priority mutex
+func (l *Logger) setComponentLevel(pattern string, p syslog.Priority) {
+	if pattern == "*" {
+		l.setLevel(p)
+		return
+	}
+	if l.levels == nil {
+		l.levels = make(map[string]syslog.Priority)
+	}
+	l.levels[pattern] = p & severityMask
+}
+
+// UnsetComponentLevel removes the level set for a component pattern so that
+// it is inherited again.
+func (l *Logger) UnsetComponentLevel(pattern string) {
+	l.priorityMu.Lock()
+	defer l.priorityMu.Unlock()
+	delete(l.levels, pattern)
+}
+
+// GetComponentLevel returns the verbosity level that a component will print
+// at and below, whether set for the component or inherited.
+func (l *Logger) GetComponentLevel(name string) syslog.Priority {
+	return l.componentLevel(levelPatterns(name))
+}
+
+// SetLevelSpec replaces the levels of all components with the levels of a
+// comma separated list of pattern=level pairs, e.g. "*=info,proxy.*=debug".
+// Patterns are as accepted by SetComponentLevel and levels as accepted by
+// ParseLevel. If the spec is invalid, no levels are changed.
+func (l *Logger) SetLevelSpec(spec string) error {
+	levels, err := parseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	l.once.Do(l.initPrinter)
+
+	l.priorityMu.Lock()
+	defer l.priorityMu.Unlock()
+	l.levels = nil
+	for _, lvl := range levels {
+		l.setComponentLevel(lvl.pattern, lvl.priority)
+	}
+	return nil
+}
+
+type patternLevel struct {
+	pattern  string
+	priority syslog.Priority
+}
+
+func parseLevelSpec(spec string) ([]patternLevel, error) {
+	var levels []patternLevel
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid level spec entry %q: missing '='", entry)
+		}
+		pattern := strings.TrimSpace(kv[0])
+		if pattern == "" {
+			return nil, fmt.Errorf("invalid level spec entry %q: empty pattern", entry)
+		}
+		lvl, err := ParseLevel(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid level spec entry %q: %v", entry, err)
+		}
+		levels = append(levels, patternLevel{pattern: pattern, priority: lvl})
+	}
+	return levels, nil
+}
+
+// levelPatterns returns the patterns that may set the level of a component,
+// most specific first. For "a.b.c" these are "a.b.c", "a.b.*", "a.b", "a.*"
+// and "a".
+func levelPatterns(name string) []string {
+	if name == "" {
+		return nil
+	}
+	patterns := []string{name}
+	for i := strings.LastIndexByte(name, '.'); i >= 0; i = strings.LastIndexByte(name, '.') {
+		name = name[:i]
+		patterns = append(patterns, name+".*", name)
+	}
+	return patterns
+}
+
+// componentLevel returns the level of the first of patterns that has one set
+// or the level of the Logger.
+func (l *Logger) componentLevel(patterns []string) syslog.Priority {
+	l.priorityMu.RLock()
+	defer l.priorityMu.RUnlock()
+
+	if len(l.levels) > 0 {
+		for _, pattern := range patterns {
+			if lvl, ok := l.levels[pattern]; ok {
+				return lvl
+			}
+		}
+	}
+	return l.getLevel()
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"log/syslog"
+	"strings"
+	"testing"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerComponentLevels(t *testing.T) {
+	logger := new(log.Logger)
+	if err := logger.SetLevelSpec("*=warning, proxy=info, proxy.*=debug, proxy.preface.conn=err"); err != nil {
+		t.Fatalf("error setting level spec: %v", err)
+	}
+
+	levels := map[string]syslog.Priority{
+		"":                   syslog.LOG_WARNING,
+		"api":                syslog.LOG_WARNING,
+		"proxy":              syslog.LOG_INFO,
+		"proxy.preface":      syslog.LOG_DEBUG,
+		"proxy.preface.conn": syslog.LOG_ERR,
+		"proxy.preface.x.y":  syslog.LOG_DEBUG,
+		"proxyx":             syslog.LOG_WARNING,
+	}
+	for name, expect := range levels {
+		if lvl := logger.GetComponentLevel(name); lvl != expect {
+			t.Errorf("expected level %d for component %q, got %d", expect, name, lvl)
+		}
+	}
+
+	// Overrides are replaced by a new spec and inherit the logger level again
+	if err := logger.SetLevelSpec("proxy.preface=debug"); err != nil {
+		t.Fatalf("error setting level spec: %v", err)
+	}
+	if lvl := logger.GetComponentLevel("proxy"); lvl != syslog.LOG_WARNING {
+		t.Errorf("expected inherited level %d for component proxy, got %d", syslog.LOG_WARNING, lvl)
+	}
+
+	// Invalid specs are rejected without changes
+	for _, spec := range []string{"proxy", "=debug", "proxy=loud"} {
+		if err := logger.SetLevelSpec(spec); err == nil {
+			t.Errorf("expected error setting level spec %q", spec)
+		}
+	}
+	if lvl := logger.GetComponentLevel("proxy.preface"); lvl != syslog.LOG_DEBUG {
+		t.Errorf("expected level %d for component proxy.preface, got %d", syslog.LOG_DEBUG, lvl)
+	}
+}
+
+func TestPrinterComponent(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetComponentLevel("proxy.*", syslog.LOG_DEBUG)
+
+	proxy := logger.Component("proxy")
+	preface := proxy.WithField("addr", "10.0.0.1").Component("preface")
+
+	proxy.Debug("hidden")
+	preface.Debug("shown")
+	expect := "]: [component=proxy.preface] [addr=10.0.0.1] shown\n"
+	if !strings.HasSuffix(buf.String(), expect) || strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("expected only %q, got %q", expect, buf.String())
+	}
+}
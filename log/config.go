@@ -0,0 +1,537 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	slog "github.com/open-ness/common/log/syslog"
+	"gopkg.in/yaml.v2"
+)
+
+// configPollInterval is how often WatchConfig checks the file for changes.
+const configPollInterval = time.Second
+
+// Config is a declarative configuration of a Logger, read from a JSON or YAML
+// file with ReadConfigFile and/or from environment variables with LoadEnv,
+// and applied with ApplyConfig. Levels are as accepted by ParseLevel.
+type Config struct {
+	// Level is the level of the Logger, see SetLevel.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Facility is the syslog facility, e.g. "local0", see SetFacility.
+	Facility string `json:"facility,omitempty" yaml:"facility,omitempty"`
+	// Components are the levels of components by pattern, see
+	// SetComponentLevel.
+	Components map[string]string `json:"components,omitempty" yaml:"components,omitempty"`
+	// Output is "stderr", the default, "stdout" or "none", see SetOutput.
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+	// Format is the format of the output: "text", the default, "json" or
+	// "logfmt", see SetOutputFormat.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Files are file sinks, see NewFileSink.
+	Files []FileConfig `json:"files,omitempty" yaml:"files,omitempty"`
+	// Syslog is the syslog connection, see ConnectSyslog.
+	Syslog *SyslogConfig `json:"syslog,omitempty" yaml:"syslog,omitempty"`
+}
+
+// FileConfig configures a FileSink.
+type FileConfig struct {
+	// Name is the name of the sink, the path if empty.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	Path string `json:"path" yaml:"path"`
+	// Level is the level of the sink, the level of the Logger if empty.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Format is "text", the default, "json" or "logfmt".
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// MaxSize is the size in bytes to rotate at, see FileMaxSize.
+	MaxSize int64 `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	// RotateEvery is a duration to rotate at multiples of, e.g. "24h", see
+	// FileRotateEvery.
+	RotateEvery string `json:"rotate_every,omitempty" yaml:"rotate_every,omitempty"`
+	// MaxBackups is the number of backups to keep, see FileMaxBackups.
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+	// MaxAge is a duration to keep backups for, e.g. "168h", see
+	// FileMaxAge.
+	MaxAge string `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+	// Compress compresses backups, see FileCompress.
+	Compress bool `json:"compress,omitempty" yaml:"compress,omitempty"`
+}
+
+// SyslogConfig configures a syslog connection.
+type SyslogConfig struct {
+	// Address is the host:port of a remote syslog, or empty for the local
+	// syslog service.
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+	// TLS connects with ConnectSyslogTLS.
+	TLS bool `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// Level is the level of logs sent to syslog, see SetSyslogLevel.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Format is "rfc3164", the default, or "rfc5424", see SyslogFormat.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// StructuredData is the SD-ID to send fields with, see
+	// SyslogStructuredData.
+	StructuredData string `json:"structured_data,omitempty" yaml:"structured_data,omitempty"`
+	// OctetCounting frames messages as specified by RFC 6587, see
+	// SyslogFraming.
+	OctetCounting bool `json:"octet_counting,omitempty" yaml:"octet_counting,omitempty"`
+	// CertFile, KeyFile and CAFile are PEM files to use with TLS, see
+	// SyslogTLSFiles.
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	CAFile   string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	// QueueSize sends logs asynchronously from a queue of this size,
+	// dropping the newest logs when it is full, see SyslogAsync.
+	QueueSize int `json:"queue_size,omitempty" yaml:"queue_size,omitempty"`
+	// SpoolDir spools logs while syslog is unreachable, see SyslogSpool.
+	SpoolDir string `json:"spool_dir,omitempty" yaml:"spool_dir,omitempty"`
+}
+
+// ReadConfigFile reads a Config from a JSON file, if path has the ".json"
+// extension, or otherwise a YAML file. Unknown keys are an error.
+func ReadConfigFile(path string) (Config, error) {
+	var c Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("error reading log config: %v", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(&c); err == io.EOF {
+			err = nil
+		}
+	} else {
+		err = yaml.UnmarshalStrict(b, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("error parsing log config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// LoadEnv overrides c with the environment variables set with the given
+// prefix, e.g. "LOG_":
+//
+//	LEVEL, FACILITY, OUTPUT, FORMAT
+//	COMPONENTS        a level spec, e.g. "proxy=debug", see SetLevelSpec
+//	FILE              the path of a file sink added to Files
+//	SYSLOG_ADDR       the address of syslog, connecting if set
+//	SYSLOG_TLS        a bool
+//	SYSLOG_LEVEL, SYSLOG_CERT_FILE, SYSLOG_KEY_FILE, SYSLOG_CA_FILE
+func (c *Config) LoadEnv(prefix string) error {
+	env := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			*dst = v
+		}
+	}
+	env("LEVEL", &c.Level)
+	env("FACILITY", &c.Facility)
+	env("OUTPUT", &c.Output)
+	env("FORMAT", &c.Format)
+
+	if spec, ok := os.LookupEnv(prefix + "COMPONENTS"); ok {
+		levels, err := parseLevelSpec(spec)
+		if err != nil {
+			return fmt.Errorf("invalid %sCOMPONENTS: %v", prefix, err)
+		}
+		c.Components = make(map[string]string, len(levels))
+		for _, lvl := range levels {
+			c.Components[lvl.pattern] = levelName(lvl.priority)
+		}
+	}
+	if path, ok := os.LookupEnv(prefix + "FILE"); ok && path != "" {
+		c.Files = append(c.Files, FileConfig{Path: path})
+	}
+
+	if addr, ok := os.LookupEnv(prefix + "SYSLOG_ADDR"); ok {
+		if c.Syslog == nil {
+			c.Syslog = new(SyslogConfig)
+		}
+		c.Syslog.Address = addr
+	}
+	if c.Syslog == nil {
+		return nil
+	}
+	if v, ok := os.LookupEnv(prefix + "SYSLOG_TLS"); ok {
+		tls, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %sSYSLOG_TLS: %v", prefix, err)
+		}
+		c.Syslog.TLS = tls
+	}
+	env("SYSLOG_LEVEL", &c.Syslog.Level)
+	env("SYSLOG_CERT_FILE", &c.Syslog.CertFile)
+	env("SYSLOG_KEY_FILE", &c.Syslog.KeyFile)
+	env("SYSLOG_CA_FILE", &c.Syslog.CAFile)
+	return nil
+}
+
+// LoadConfig reads a Config from the file at path, unless it is empty, and
+// overrides it with the environment variables with envPrefix.
+func LoadConfig(path, envPrefix string) (Config, error) {
+	var (
+		c   Config
+		err error
+	)
+	if path != "" {
+		if c, err = ReadConfigFile(path); err != nil {
+			return c, err
+		}
+	}
+	err = c.LoadEnv(envPrefix)
+	return c, err
+}
+
+// ApplyConfig changes l to match c, after validating it. Empty values leave
+// the corresponding settings unchanged, except that the levels of components
+// are replaced by those of c, and that file sinks and the syslog connection
+// added by a previous config but not by c are removed. File sinks and the
+// syslog connection are only re-opened if their config changed. If any of
+// them fails to open, the rest of c is still applied and all errors are
+// returned together.
+func (l *Logger) ApplyConfig(c Config) error {
+	p, err := c.plan()
+	if err != nil {
+		return err
+	}
+	l.once.Do(l.initPrinter)
+
+	l.configMu.Lock()
+	defer l.configMu.Unlock()
+
+	if c.Level != "" {
+		l.SetLevel(p.level)
+	}
+	if c.Facility != "" {
+		l.SetFacility(p.facility)
+	}
+	l.priorityMu.Lock()
+	l.levels = nil
+	for pattern, lvl := range p.components {
+		l.setComponentLevel(pattern, lvl)
+	}
+	l.priorityMu.Unlock()
+	if c.Output != "" {
+		l.SetOutput(p.output)
+	}
+	if c.Format != "" {
+		l.SetEncoder(p.encoder)
+	}
+
+	// Apply syslog even if a file sink fails, reporting all errors
+	prev := l.config
+	l.config = &Config{}
+	errs := l.applyFiles(prev, c, p)
+	if err := l.applySyslog(prev, c, p); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyFiles returns the errors adding file sinks. Only call with a lock on
+// the config mutex.
+func (l *Logger) applyFiles(prev *Config, c Config, p configPlan) []string {
+	applied := make(map[string]FileConfig)
+	if prev != nil {
+		for _, f := range prev.Files {
+			applied[f.sinkName()] = f
+		}
+	}
+
+	var errs []string
+	for i, f := range c.Files {
+		name := f.sinkName()
+		if old, ok := applied[name]; ok {
+			delete(applied, name)
+			old.Level = f.Level // changed in place
+			if old == f && l.SetSinkLevel(name, p.fileLevels[i]) == nil {
+				l.config.Files = append(l.config.Files, f)
+				continue
+			}
+			_ = l.RemoveSink(name)
+		}
+
+		s, err := NewFileSink(f.Path, p.fileOpts[i]...)
+		if err == nil {
+			if err = l.AddSink(name, s, p.fileLevels[i]); err != nil {
+				_ = s.Close()
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("error adding file sink %s: %v", name, err))
+			continue
+		}
+		l.config.Files = append(l.config.Files, f)
+	}
+	for name := range applied {
+		_ = l.RemoveSink(name)
+	}
+	return errs
+}
+
+// Only call with a lock on the config mutex
+func (l *Logger) applySyslog(prev *Config, c Config, p configPlan) error {
+	var old *SyslogConfig
+	if prev != nil {
+		old = prev.Syslog
+	}
+	// A connection that fails to be replaced is kept, so keep its config to
+	// disconnect it once no longer configured
+	l.config.Syslog = old
+	if c.Syslog == nil {
+		if old != nil {
+			l.config.Syslog = nil
+			return l.DisconnectSyslog()
+		}
+		return nil
+	}
+
+	if old != nil {
+		cmp := *old
+		cmp.Level = c.Syslog.Level // changed in place
+		if cmp == *c.Syslog {
+			if c.Syslog.Level != "" {
+				l.SetSyslogLevel(p.syslogLevel)
+			}
+			l.config.Syslog = c.Syslog
+			return nil
+		}
+	}
+
+	var err error
+	if c.Syslog.TLS {
+		err = l.ConnectSyslogTLS(c.Syslog.Address, nil, p.syslogOpts...)
+	} else {
+		err = l.ConnectSyslog(c.Syslog.Address, p.syslogOpts...)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to syslog: %v", err)
+	}
+	l.config.Syslog = c.Syslog
+	return nil
+}
+
+func (f FileConfig) sinkName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.Path
+}
+
+// configPlan holds the parsed values of a Config.
+type configPlan struct {
+	level, facility syslog.Priority
+	components      map[string]syslog.Priority
+	output          io.Writer
+	encoder         Encoder
+	fileLevels      []syslog.Priority
+	fileOpts        [][]FileOption
+	syslogLevel     syslog.Priority
+	syslogOpts      []SyslogOption
+}
+
+// plan parses and validates c.
+func (c Config) plan() (configPlan, error) { //nolint: gocyclo
+	var (
+		p   configPlan
+		err error
+	)
+	if c.Level != "" {
+		if p.level, err = ParseLevel(c.Level); err != nil {
+			return p, err
+		}
+	}
+	if c.Facility != "" {
+		if p.facility, err = parseFacility(c.Facility); err != nil {
+			return p, err
+		}
+	}
+	p.components = make(map[string]syslog.Priority, len(c.Components))
+	for pattern, name := range c.Components {
+		if p.components[pattern], err = ParseLevel(name); err != nil {
+			return p, fmt.Errorf("invalid level of component %q: %v", pattern, err)
+		}
+	}
+	switch strings.ToLower(c.Output) {
+	case "", "stderr":
+		p.output = os.Stderr
+	case "stdout":
+		p.output = os.Stdout
+	case "none":
+		p.output = ioutil.Discard
+	default:
+		return p, fmt.Errorf("invalid output %q", c.Output)
+	}
+	if p.encoder, err = parseEncoder(c.Format); err != nil {
+		return p, err
+	}
+
+	names := map[string]bool{OutputSink: true, SyslogSink: true}
+	for _, f := range c.Files {
+		if f.Path == "" {
+			return p, fmt.Errorf("file sink %q has no path", f.Name)
+		}
+		if names[f.sinkName()] {
+			return p, fmt.Errorf("duplicate sink %q", f.sinkName())
+		}
+		names[f.sinkName()] = true
+
+		lvl := LevelInherit
+		if f.Level != "" {
+			if lvl, err = ParseLevel(f.Level); err != nil {
+				return p, fmt.Errorf("invalid level of file sink %q: %v", f.sinkName(), err)
+			}
+		}
+		enc, err := parseEncoder(f.Format)
+		if err != nil {
+			return p, err
+		}
+		opts := []FileOption{FileEncoder(enc), FileMaxSize(f.MaxSize), FileMaxBackups(f.MaxBackups)}
+		if f.RotateEvery != "" {
+			d, err := time.ParseDuration(f.RotateEvery)
+			if err != nil {
+				return p, fmt.Errorf("invalid rotate_every of file sink %q: %v", f.sinkName(), err)
+			}
+			opts = append(opts, FileRotateEvery(d))
+		}
+		if f.MaxAge != "" {
+			d, err := time.ParseDuration(f.MaxAge)
+			if err != nil {
+				return p, fmt.Errorf("invalid max_age of file sink %q: %v", f.sinkName(), err)
+			}
+			opts = append(opts, FileMaxAge(d))
+		}
+		if f.Compress {
+			opts = append(opts, FileCompress())
+		}
+		p.fileLevels = append(p.fileLevels, lvl)
+		p.fileOpts = append(p.fileOpts, opts)
+	}
+
+	if s := c.Syslog; s != nil {
+		if s.Level != "" {
+			if p.syslogLevel, err = ParseLevel(s.Level); err != nil {
+				return p, fmt.Errorf("invalid level of syslog: %v", err)
+			}
+			p.syslogOpts = append(p.syslogOpts, SyslogLevel(p.syslogLevel))
+		}
+		switch strings.ToLower(s.Format) {
+		case "", "rfc3164":
+		case "rfc5424":
+			p.syslogOpts = append(p.syslogOpts, SyslogFormat(slog.RFC5424))
+		default:
+			return p, fmt.Errorf("invalid syslog format %q", s.Format)
+		}
+		if s.StructuredData != "" {
+			if err := slog.ValidSDID(s.StructuredData); err != nil {
+				return p, err
+			}
+			p.syslogOpts = append(p.syslogOpts, SyslogStructuredData(s.StructuredData))
+		}
+		if s.OctetCounting {
+			p.syslogOpts = append(p.syslogOpts, SyslogFraming(slog.OctetCounting))
+		}
+		if s.CertFile != "" || s.KeyFile != "" || s.CAFile != "" {
+			if !s.TLS {
+				return p, fmt.Errorf("syslog TLS files require tls")
+			}
+			p.syslogOpts = append(p.syslogOpts, SyslogTLSFiles(s.CertFile, s.KeyFile, s.CAFile))
+		}
+		if s.TLS && s.Address == "" {
+			return p, fmt.Errorf("syslog tls requires an address")
+		}
+		if s.QueueSize > 0 {
+			p.syslogOpts = append(p.syslogOpts, SyslogAsync(s.QueueSize, OverflowDropNewest))
+		}
+		if s.SpoolDir != "" {
+			p.syslogOpts = append(p.syslogOpts, SyslogSpool(s.SpoolDir, 0, 0))
+		}
+	}
+	return p, nil
+}
+
+// parseEncoder returns the Encoder of a format name.
+func parseEncoder(format string) (Encoder, error) {
+	switch strings.ToLower(format) {
+	case "", "text":
+		return TextEncoder{}, nil
+	case "json":
+		return JSONEncoder{}, nil
+	case "logfmt":
+		return LogfmtEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("invalid format %q", format)
+	}
+}
+
+// WatchConfig applies the config loaded by LoadConfig(path, envPrefix) to l
+// and then re-applies it when the file changes or on SIGHUP, until ctx is
+// done. On SIGHUP, l is then reloaded as by SignalReloads, e.g. to reopen
+// files moved by logrotate. An error is returned if the config cannot be
+// applied initially, and later errors are logged by l.
+//
+// This function spawns a goroutine in order to make it safe to send a HUP
+// signal as soon as the function has returned.
+func WatchConfig(ctx context.Context, l *Logger, path, envPrefix string) error {
+	var (
+		mu    sync.Mutex
+		stamp = statFile(path)
+	)
+	c, err := LoadConfig(path, envPrefix)
+	if err != nil {
+		return err
+	}
+	if err := l.ApplyConfig(c); err != nil {
+		return err
+	}
+
+	// apply re-applies the config, unless changed is set and the file has
+	// not changed
+	apply := func(changed bool) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if changed && (path == "" || statFile(path) == stamp) {
+			return nil
+		}
+		stamp = statFile(path)
+		c, err := LoadConfig(path, envPrefix)
+		if err != nil {
+			return err
+		}
+		return l.ApplyConfig(c)
+	}
+	l.watchHUP(ctx, &hupHook{apply: func() error { return apply(false) }})
+
+	go func() {
+		t := time.NewTicker(configPollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if err := apply(true); err != nil {
+				l.Err(err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	expect := log.Config{
+		Level:      "debug",
+		Components: map[string]string{"proxy.*": "err"},
+		Files:      []log.FileConfig{{Path: "/var/log/app.log", Level: "info", MaxBackups: 3}},
+		Syslog:     &log.SyslogConfig{Address: "collector:6514", TLS: true},
+	}
+	files := map[string]string{
+		"log.yaml": `
+level: debug
+components:
+  proxy.*: err
+files:
+- path: /var/log/app.log
+  level: info
+  max_backups: 3
+syslog:
+  address: collector:6514
+  tls: true
+`,
+		"log.json": `{
+  "level": "debug",
+  "components": {"proxy.*": "err"},
+  "files": [{"path": "/var/log/app.log", "level": "info", "max_backups": 3}],
+  "syslog": {"address": "collector:6514", "tls": true}
+}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := log.ReadConfigFile(path)
+		if err != nil {
+			t.Errorf("error reading %s: %v", name, err)
+		} else if !reflect.DeepEqual(c, expect) {
+			t.Errorf("unexpected config from %s: %+v", name, c)
+		}
+	}
+
+	// Expect unknown keys to be an error
+	path := filepath.Join(dir, "unknown.yaml")
+	if err := ioutil.WriteFile(path, []byte("levle: debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.ReadConfigFile(path); err == nil {
+		t.Errorf("expected error reading unknown key")
+	}
+}
+
+func TestConfigLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"TEST_LOG_LEVEL":       "warning",
+		"TEST_LOG_COMPONENTS":  "proxy=debug, proxy.preface=err",
+		"TEST_LOG_FILE":        "/var/log/app.log",
+		"TEST_LOG_SYSLOG_ADDR": "collector:514",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	c := log.Config{Level: "info", Format: "json"}
+	if err := c.LoadEnv("TEST_LOG_"); err != nil {
+		t.Fatalf("error loading env: %v", err)
+	}
+	expect := log.Config{
+		Level:      "warning",
+		Format:     "json",
+		Components: map[string]string{"proxy": "debug", "proxy.preface": "err"},
+		Files:      []log.FileConfig{{Path: "/var/log/app.log"}},
+		Syslog:     &log.SyslogConfig{Address: "collector:514"},
+	}
+	if !reflect.DeepEqual(c, expect) {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	os.Setenv("TEST_LOG_SYSLOG_TLS", "maybe")
+	defer os.Unsetenv("TEST_LOG_SYSLOG_TLS")
+	if err := c.LoadEnv("TEST_LOG_"); err == nil {
+		t.Errorf("expected error loading invalid bool")
+	}
+}
+
+func TestLoggerApplyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	debugPath := filepath.Join(dir, "debug.log")
+	auditPath := filepath.Join(dir, "audit.log")
+
+	logger := new(log.Logger)
+	logger.SetComponentLevel("old", syslog.LOG_DEBUG)
+	err = logger.ApplyConfig(log.Config{
+		Level:      "warning",
+		Facility:   "local3",
+		Components: map[string]string{"proxy": "info"},
+		Output:     "none",
+		Files: []log.FileConfig{
+			{Name: "debug", Path: debugPath, Level: "debug", Format: "json"},
+			{Path: auditPath},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error applying config: %v", err)
+	}
+	if lvl := logger.GetLevel(); lvl != syslog.LOG_WARNING {
+		t.Errorf("expected level %v, got %v", syslog.LOG_WARNING, lvl)
+	}
+	if fac := logger.GetFacility(); fac != syslog.LOG_LOCAL3 {
+		t.Errorf("expected facility %v, got %v", syslog.LOG_LOCAL3, fac)
+	}
+	if lvl := logger.GetComponentLevel("old"); lvl != syslog.LOG_WARNING {
+		t.Errorf("expected component level replaced, got %v", lvl)
+	}
+	if names := logger.SinkNames(); !reflect.DeepEqual(names, []string{"output", "debug", auditPath}) {
+		t.Errorf("unexpected sink names %v", names)
+	}
+	logger.Info("first")
+
+	// Expect an invalid config to change nothing
+	if err := logger.ApplyConfig(log.Config{Level: "debug", Files: []log.FileConfig{{Name: "x"}}}); err == nil {
+		t.Errorf("expected error applying file without path")
+	}
+	if lvl := logger.GetLevel(); lvl != syslog.LOG_WARNING {
+		t.Errorf("expected level unchanged, got %v", lvl)
+	}
+
+	// Expect a file sink with only a new level to be kept open and one
+	// no longer configured to be removed
+	err = logger.ApplyConfig(log.Config{
+		Files: []log.FileConfig{{Name: "debug", Path: debugPath, Level: "info", Format: "json"}},
+	})
+	if err != nil {
+		t.Fatalf("error applying config: %v", err)
+	}
+	if names := logger.SinkNames(); !reflect.DeepEqual(names, []string{"output", "debug"}) {
+		t.Errorf("unexpected sink names %v", names)
+	}
+	if lvl, _ := logger.GetSinkLevel("debug"); lvl != syslog.LOG_INFO {
+		t.Errorf("expected debug sink level %v, got %v", syslog.LOG_INFO, lvl)
+	}
+	logger.Debug("hidden")
+	logger.Info("second")
+	b, err := ioutil.ReadFile(debugPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 2 ||
+		!strings.Contains(lines[0], `"message":"first"`) || !strings.Contains(lines[1], `"message":"second"`) {
+		t.Errorf("unexpected debug log %q", b)
+	}
+}
+
+func TestLoggerApplyConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	notDir := filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	conn := listenSyslog(t)
+	defer conn.Close()
+
+	// Expect syslog and other files to be applied when a file fails
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	err = logger.ApplyConfig(log.Config{
+		Files: []log.FileConfig{
+			{Name: "broken", Path: filepath.Join(notDir, "app.log")},
+			{Name: "ok", Path: filepath.Join(dir, "app.log")},
+		},
+		Syslog: &log.SyslogConfig{Address: conn.LocalAddr().String()},
+	})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected error adding broken file sink, got %v", err)
+	}
+	if names := logger.SinkNames(); !reflect.DeepEqual(names, []string{"output", "ok", "syslog"}) {
+		t.Errorf("unexpected sink names %v", names)
+	}
+	logger.Info("connected")
+	if msg := readSyslog(t, conn); !strings.HasSuffix(msg, "connected\n") {
+		t.Errorf("unexpected syslog message %q", msg)
+	}
+
+	// Expect a connection kept after failing to replace it to be
+	// disconnected once no longer configured
+	err = logger.ApplyConfig(log.Config{Syslog: &log.SyslogConfig{Address: "invalid:address"}})
+	if err == nil {
+		t.Errorf("expected error connecting to invalid address")
+	}
+	if names := logger.SinkNames(); !reflect.DeepEqual(names, []string{"output", "syslog"}) {
+		t.Errorf("unexpected sink names %v", names)
+	}
+	if err = logger.ApplyConfig(log.Config{}); err != nil {
+		t.Errorf("error applying config: %v", err)
+	}
+	if names := logger.SinkNames(); !reflect.DeepEqual(names, []string{"output"}) {
+		t.Errorf("unexpected sink names %v", names)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.yaml")
+	if err := ioutil.WriteFile(path, []byte("level: err\noutput: none\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := new(log.Logger)
+	if err := log.WatchConfig(ctx, logger, path, "TEST_LOG_"); err != nil {
+		t.Fatalf("error watching config: %v", err)
+	}
+	if lvl := logger.GetLevel(); lvl != syslog.LOG_ERR {
+		t.Errorf("expected level %v, got %v", syslog.LOG_ERR, lvl)
+	}
+
+	waitLevel := func(expect syslog.Priority) {
+		t.Helper()
+		for start := time.Now(); logger.GetLevel() != expect; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 3*time.Second {
+				t.Fatalf("expected level %v, got %v", expect, logger.GetLevel())
+			}
+		}
+	}
+
+	// Expect a change of the file to be applied
+	if err := ioutil.WriteFile(path, []byte("level: notice\noutput: none\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(syslog.LOG_NOTICE)
+
+	// Expect a change of the environment to be applied on SIGHUP
+	os.Setenv("TEST_LOG_LEVEL", "debug")
+	defer os.Unsetenv("TEST_LOG_LEVEL")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("got error sending HUP signal to self: %v", err)
+	}
+	waitLevel(syslog.LOG_DEBUG)
+
+	// Expect SIGHUP to also reload sinks, once with SignalReloads too
+	sink := reloadSink{reloads: make(chan struct{}, 2)}
+	if err := logger.AddSink("reload", sink, log.LevelInherit); err != nil {
+		t.Fatalf("error adding sink: %v", err)
+	}
+	for _, lvl := range []string{"info", "warning"} {
+		os.Setenv("TEST_LOG_LEVEL", lvl)
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatalf("got error sending HUP signal to self: %v", err)
+		}
+		select {
+		case <-sink.reloads:
+		case <-time.After(time.Second):
+			t.Fatal("timed out before signal reloaded sink")
+		}
+		select {
+		case <-sink.reloads:
+			t.Error("expected sink reloaded once")
+		case <-time.After(50 * time.Millisecond):
+		}
+		log.SignalReloads(ctx, logger)
+	}
+	waitLevel(syslog.LOG_WARNING)
+}
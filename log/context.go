@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+// ContextExtractor returns fields to tag logs with from values of a context,
+// such as a request ID. It must return nil if the context has no relevant
+// values.
+type ContextExtractor func(ctx context.Context) []Field
+
+type printerKey struct{}
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   []ContextExtractor
+)
+
+// RegisterContextExtractor adds an extractor used by Printer.WithContext and
+// FromContext. It is usually called from an init func.
+func RegisterContextExtractor(e ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors = append(extractors, e)
+}
+
+// NewContext returns a copy of ctx carrying p, which can be retrieved with
+// FromContext.
+func NewContext(ctx context.Context, p Printer) context.Context {
+	return context.WithValue(ctx, printerKey{}, p)
+}
+
+// FromContext returns the Printer carried by ctx or, if there is none, a
+// Printer of DefaultLogger, tagged with the fields of all registered
+// extractors.
+func FromContext(ctx context.Context) Printer {
+	p, ok := ctx.Value(printerKey{}).(Printer)
+	if !ok {
+		p = DefaultLogger.With()
+	}
+	return p.WithContext(ctx)
+}
+
+// WithContext returns a child Printer tagged with the fields of p and the
+// fields returned by all registered extractors for ctx.
+func (p Printer) WithContext(ctx context.Context) Printer {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	var fields []Field
+	for _, extract := range extractors {
+		fields = append(fields, extract(ctx)...)
+	}
+	return p.With(fields...)
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/open-ness/common/log"
+)
+
+type requestIDKey struct{}
+
+func init() {
+	log.RegisterContextExtractor(func(ctx context.Context) []log.Field {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return []log.Field{log.String("request_id", id)}
+		}
+		return nil
+	})
+}
+
+func TestContext(t *testing.T) {
+	defer func() { log.DefaultLogger = new(log.Logger) }()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+
+	// Without a Printer in the context the default logger is used
+	log.FromContext(ctx).Info("default")
+
+	// With a Printer in the context it is used with the extracted fields
+	ctx = log.NewContext(ctx, logger.WithField("component", "api"))
+	log.FromContext(ctx).Info("attached")
+
+	// Without extracted fields the Printer is unchanged
+	logger.WithField("component", "api").WithContext(context.Background()).Info("plain")
+
+	expect := []string{
+		": [request_id=abc] default\n",
+		": [component=api] [request_id=abc] attached\n",
+		": [component=api] plain\n",
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, line := range expect {
+		if !strings.HasSuffix(lines[i], line) {
+			t.Errorf("expected %q to end with %q", lines[i], line)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RepeatedKey is the key of the field holding the number of repetitions in
+// the record written by dedup in place of identical consecutive logs.
+const RepeatedKey = "repeated"
+
+// SetSinkDedup collapses identical consecutive logs written to a named sink,
+// like classic syslogd. Logs are identical if they have the same priority,
+// fields and message. Only the first log of a run is written, followed by
+// "last message repeated N times" when a different log is written or, if
+// flush is positive, at most flush after the first repetition, so that a
+// long run is still reported. Any pending repetitions are written when the
+// sink is removed or dedup is unset.
+func (l *Logger) SetSinkDedup(name string, flush time.Duration) error {
+	return l.replaceDedup(name, func(s Sink) *dedup {
+		return &dedup{sink: s, flush: flush, logger: l, name: name}
+	})
+}
+
+// UnsetSinkDedup stops collapsing identical consecutive logs written to a
+// named sink, writing any pending repetitions.
+func (l *Logger) UnsetSinkDedup(name string) error {
+	return l.replaceDedup(name, func(Sink) *dedup { return nil })
+}
+
+func (l *Logger) replaceDedup(name string, next func(Sink) *dedup) error {
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	i := l.sinkIndex(name)
+	if i < 0 {
+		l.sinksMu.Unlock()
+		return fmt.Errorf("sink %q does not exist", name)
+	}
+	sinks := make([]sinkEntry, len(l.sinks))
+	copy(sinks, l.sinks)
+	prev := sinks[i].dedup
+	sinks[i].dedup = next(sinks[i].sink)
+	l.sinks = sinks
+	l.sinksMu.Unlock()
+
+	// Write pending repetitions without holding the sinks lock
+	prev.stop()
+	return nil
+}
+
+// dedup collapses identical consecutive records written to a sink.
+type dedup struct {
+	sink   Sink
+	flush  time.Duration
+	logger *Logger // to report errors writing repetitions
+	name   string
+
+	mu      sync.Mutex // held while writing to keep records in order
+	last    *Record    // copy of the last record written
+	repeats int        // of last since last written or flushed
+	at      time.Time  // of the latest repetition
+	timer   *time.Timer
+	stopped bool
+}
+
+// WriteRecord writes r to the sink unless it is identical to the last record,
+// in which case it is counted.
+func (d *dedup) WriteRecord(r *Record) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return d.sink.WriteRecord(r)
+	}
+
+	if d.last != nil && sameRecord(d.last, r) {
+		d.repeats++
+		d.at = r.Time
+		if d.timer == nil && d.flush > 0 {
+			d.timer = time.AfterFunc(d.flush, d.flushRepeats)
+		}
+		return nil
+	}
+
+	d.writeRepeats()
+	rec := *r
+	d.last = &rec
+	return d.sink.WriteRecord(r)
+}
+
+// to returns a dedup with the same settings writing to s, or nil if d is
+// nil, for a sink replacing the sink of d.
+func (d *dedup) to(s Sink) *dedup {
+	if d == nil {
+		return nil
+	}
+	return &dedup{sink: s, flush: d.flush, logger: d.logger, name: d.name}
+}
+
+// flushRepeats writes the repetitions counted so far, continuing the run.
+func (d *dedup) flushRepeats() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timer = nil
+	if !d.stopped {
+		d.writeRepeats()
+	}
+}
+
+// stop writes any pending repetitions and passes later records through. It
+// may be called on a nil dedup.
+func (d *dedup) stop() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	d.stopped = true
+	d.writeRepeats()
+	d.last = nil
+}
+
+// Only call with a lock on the mutex
+func (d *dedup) writeRepeats() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.repeats == 0 {
+		return
+	}
+
+	rec := *d.last
+	rec.Time = d.at
+	rec.Fields = mergeFields(d.last.Fields, []Field{Int(RepeatedKey, d.repeats)})
+	if d.repeats == 1 {
+		rec.Message = "last message repeated 1 time"
+	} else {
+		rec.Message = fmt.Sprintf("last message repeated %d times", d.repeats)
+	}
+	d.repeats = 0
+	if err := d.sink.WriteRecord(&rec); err != nil {
+		d.logger.sinkError(d.name, &rec, err)
+	}
+}
+
+// sameRecord reports whether two records have the same priority, fields and
+// message.
+func sameRecord(a, b *Record) bool {
+	if a.Priority != b.Priority || a.Message != b.Message || len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i := range a.Fields {
+		f, g := a.Fields[i], b.Fields[i]
+		if f.Key != g.Key || f.kind != g.kind || f.num != g.num || f.str != g.str {
+			return false
+		}
+		// Values of other kinds may not be comparable
+		if f.any != nil && f.String() != g.String() {
+			return false
+		}
+	}
+	return true
+}
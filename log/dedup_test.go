@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"log/syslog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerSinkDedup(t *testing.T) {
+	var (
+		text   syncBuffer
+		custom closeBuffer
+		logger = new(log.Logger)
+	)
+	logger.SetOutput(&text)
+	if err := logger.AddSink("custom", &custom, syslog.LOG_DEBUG); err != nil {
+		t.Fatalf("error adding custom sink: %v", err)
+	}
+	if err := logger.SetSinkDedup("missing", 0); err == nil {
+		t.Errorf("expected error setting dedup of missing sink")
+	}
+	if err := logger.SetSinkDedup(log.OutputSink, 0); err != nil {
+		t.Fatalf("error setting dedup: %v", err)
+	}
+
+	// Expect runs of identical logs to be collapsed in the output sink only
+	for i := 0; i < 3; i++ {
+		logger.Info("link down")
+	}
+	logger.Warning("link down")
+	logger.Component("eth0").Warning("link down")
+	logger.Component("eth0").Warning("link down")
+	logger.Info("link up")
+	expect := []string{
+		"link down",
+		"[repeated=2] last message repeated 2 times",
+		"link down",
+		"[component=eth0] link down",
+		"[component=eth0] [repeated=1] last message repeated 1 time",
+		"link up",
+	}
+	lines := strings.Split(strings.TrimSuffix(text.String(), "\n"), "\n")
+	if len(lines) != len(expect) {
+		t.Fatalf("expected %d lines of output, got %q", len(expect), text.String())
+	}
+	for i := range expect {
+		if !strings.HasSuffix(lines[i], expect[i]) {
+			t.Errorf("expected line %d to end with %q, got %q", i, expect[i], lines[i])
+		}
+	}
+	if n := strings.Count(custom.String(), "link down"); n != 6 {
+		t.Errorf("expected 6 logs in custom sink, got %q", custom.String())
+	}
+
+	// Expect repetitions to be written on removal
+	if err := logger.SetSinkDedup("custom", 0); err != nil {
+		t.Fatalf("error setting dedup: %v", err)
+	}
+	logger.Info("link up")
+	logger.Info("link up")
+	if err := logger.RemoveSink("custom"); err != nil {
+		t.Fatalf("error removing custom sink: %v", err)
+	}
+	if !strings.HasSuffix(custom.String(), "link up\nlast message repeated 1 time\n") || !custom.closed {
+		t.Errorf("expected repetitions written before close, got %q", custom.String())
+	}
+}
+
+func TestLoggerSinkDedupFlush(t *testing.T) {
+	var text syncBuffer
+	logger := new(log.Logger)
+	logger.SetOutput(&text)
+	if err := logger.SetSinkDedup(log.OutputSink, 100*time.Millisecond); err != nil {
+		t.Fatalf("error setting dedup: %v", err)
+	}
+
+	// Expect a long run to be reported after the flush interval
+	for i := 0; i < 5; i++ {
+		logger.Err("disk full")
+	}
+	for start := time.Now(); !strings.Contains(text.String(), "repeated 4 times"); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("expected repetitions flushed in output %q", text.String())
+		}
+	}
+	logger.Err("disk full")
+	if err := logger.UnsetSinkDedup(log.OutputSink); err != nil {
+		t.Fatalf("error unsetting dedup: %v", err)
+	}
+	logger.Err("disk full")
+	if !strings.Contains(text.String(), "repeated 1 time\n") {
+		t.Errorf("expected repetitions written when unset in output %q", text.String())
+	}
+	if n := strings.Count(text.String(), "disk full"); n != 2 {
+		t.Errorf("expected 2 disk full logs, got %q", text.String())
+	}
+}
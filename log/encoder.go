@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"log/syslog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Record is a single log as passed to an Encoder.
+type Record struct {
+	// Time is when the log was printed.
+	Time time.Time
+	// Priority is the combined facility and severity of the log.
+	Priority syslog.Priority
+	// Service is the name of the executable printing the log.
+	Service string
+	// PID is the process ID of the executable printing the log.
+	PID int
+	// Fields are the fields of the Printer printing the log, in order.
+	Fields []Field
+	// Message is the formatted message without a trailing newline.
+	Message string
+}
+
+// Encoder renders a Record as bytes, including any line terminator, to be
+// written to the local output of a Logger.
+type Encoder interface {
+	Encode(r *Record) ([]byte, error)
+}
+
+// EncoderFunc is an adapter to allow the use of ordinary funcs as Encoders.
+type EncoderFunc func(r *Record) ([]byte, error)
+
+// Encode calls f(r).
+func (f EncoderFunc) Encode(r *Record) ([]byte, error) { return f(r) }
+
+// TextEncoder renders records as syslog-like lines with fields as
+// "[key=value]" prefixes of the message:
+//
+//	<PRI>TIMESTAMP SERVICE[PID]: [key=value] MSG
+//
+// This is the default Encoder of a Logger.
+type TextEncoder struct{}
+
+// Encode implements Encoder.
+func (TextEncoder) Encode(r *Record) ([]byte, error) {
+	buf := make([]byte, 0, 64+len(r.Message))
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(r.Priority), 10)
+	buf = append(buf, '>')
+	buf = r.Time.AppendFormat(buf, time.Stamp)
+	buf = append(buf, ' ')
+	buf = append(buf, r.Service...)
+	buf = append(buf, '[')
+	buf = strconv.AppendInt(buf, int64(r.PID), 10)
+	buf = append(buf, "]: "...)
+	buf = appendFieldTags(buf, r.Fields)
+	buf = append(buf, r.Message...)
+	return append(buf, '\n'), nil
+}
+
+// LogfmtEncoder renders records as logfmt lines:
+//
+//	time=TIMESTAMP level=LEVEL facility=FACILITY service=SERVICE pid=PID msg=MSG key=value
+//
+// Values are quoted when necessary and fields with nil values are rendered as
+// bare keys.
+type LogfmtEncoder struct{}
+
+// Encode implements Encoder.
+func (LogfmtEncoder) Encode(r *Record) ([]byte, error) {
+	buf := make([]byte, 0, 128+len(r.Message))
+	buf = append(buf, "time="...)
+	buf = r.Time.UTC().AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, " level="...)
+	buf = append(buf, levelName(r.Priority)...)
+	buf = append(buf, " facility="...)
+	buf = append(buf, facilityName(r.Priority)...)
+	buf = append(buf, " service="...)
+	buf = appendLogfmtValue(buf, r.Service)
+	buf = append(buf, " pid="...)
+	buf = strconv.AppendInt(buf, int64(r.PID), 10)
+	buf = append(buf, " msg="...)
+	buf = appendLogfmtValue(buf, r.Message)
+
+	for _, f := range r.Fields {
+		buf = append(buf, ' ')
+		buf = append(buf, logfmtKey(f.Key)...)
+		if !f.IsNil() {
+			buf = append(buf, '=')
+			buf = appendLogfmtValue(buf, f.String())
+		}
+	}
+	return append(buf, '\n'), nil
+}
+
+// logfmtKey replaces characters not allowed in a logfmt key with underscores.
+func logfmtKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key)
+	if key == "" {
+		return "_"
+	}
+	return key
+}
+
+// appendLogfmtValue appends s to buf, quoting it if it is empty or contains
+// spaces, quotes, equal signs or control characters.
+func appendLogfmtValue(buf []byte, s string) []byte {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError
+	}) < 0 {
+		return append(buf, s...)
+	}
+	return strconv.AppendQuote(buf, s)
+}
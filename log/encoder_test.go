@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"log/syslog"
+	"regexp"
+	"testing"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerEncoders(t *testing.T) {
+	fields := map[string]interface{}{"b": "x y", "a": 1, "c": nil}
+
+	tests := map[string]struct {
+		encoder log.Encoder
+		expect  *regexp.Regexp
+	}{
+		"text": {
+			encoder: log.TextEncoder{},
+			expect:  regexp.MustCompile(`^<156>\w{3} [ \d]\d \d\d:\d\d:\d\d \S+\[\d+\]: \[a=1\] \[b=x y\] \[c\] hello\n$`),
+		},
+		"logfmt": {
+			encoder: log.LogfmtEncoder{},
+			expect: regexp.MustCompile(`^time=\S+ level=warning facility=local3 service=\S+ pid=\d+ ` +
+				`msg=hello a=1 b="x y" c\n$`),
+		},
+		"custom": {
+			encoder: log.EncoderFunc(func(r *log.Record) ([]byte, error) {
+				return []byte(r.Message + " " + r.Fields[1].String() + "\n"), nil
+			}),
+			expect: regexp.MustCompile(`^hello x y\n$`),
+		},
+	}
+
+	for desc, test := range tests {
+		var buf bytes.Buffer
+
+		logger := new(log.Logger)
+		logger.SetOutput(&buf)
+		logger.SetFacility(syslog.LOG_LOCAL3)
+		logger.SetEncoder(test.encoder)
+
+		logger.WithFields(fields).Warningln("hello")
+		if actual := buf.String(); !test.expect.MatchString(actual) {
+			t.Errorf("[%s] expected to match regexp %q, got %q", desc, test.expect, actual)
+		}
+	}
+}
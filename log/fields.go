@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	slog "github.com/open-ness/common/log/syslog"
+)
+
+type fieldKind uint8
+
+const (
+	nilKind fieldKind = iota
+	stringKind
+	intKind
+	uintKind
+	floatKind
+	boolKind
+	durationKind
+	timeKind
+	errorKind
+	anyKind
+)
+
+// Field is a typed key-value pair tagging each log of a Printer. Fields are
+// created with String, Int, Bool, etc. and are rendered in the order given
+// without using reflection for any of the typed constructors.
+type Field struct {
+	Key string
+
+	kind fieldKind
+	num  uint64
+	str  string
+	any  interface{}
+}
+
+// String returns a string Field.
+func String(key, value string) Field { return Field{Key: key, kind: stringKind, str: value} }
+
+// Int returns an integer Field.
+func Int(key string, value int) Field { return Int64(key, int64(value)) }
+
+// Int64 returns an integer Field.
+func Int64(key string, value int64) Field { return Field{Key: key, kind: intKind, num: uint64(value)} }
+
+// Uint64 returns an unsigned integer Field.
+func Uint64(key string, value uint64) Field { return Field{Key: key, kind: uintKind, num: value} }
+
+// Float64 returns a floating point Field.
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatKind, num: math.Float64bits(value)}
+}
+
+// Bool returns a boolean Field.
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: boolKind}
+	if value {
+		f.num = 1
+	}
+	return f
+}
+
+// Duration returns a Field rendered like time.Duration.String.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationKind, num: uint64(value)}
+}
+
+// Time returns a Field rendered in the time.RFC3339Nano format.
+func Time(key string, value time.Time) Field { return Field{Key: key, kind: timeKind, any: value} }
+
+// NamedErr returns a Field holding the message of err. A nil err results in a
+// Field with a nil value.
+func NamedErr(key string, err error) Field {
+	if err == nil {
+		return Field{Key: key}
+	}
+	return Field{Key: key, kind: errorKind, any: err}
+}
+
+// ErrField returns a Field with the key "error" holding the message of err.
+// It is not named Err, which prints at the ERR level.
+func ErrField(err error) Field { return NamedErr("error", err) }
+
+// Any returns a Field for an arbitrary value, using the typed constructors
+// for values of their types. A nil value is rendered as a bare key in text
+// output.
+func Any(key string, value interface{}) Field { //nolint: gocyclo
+	switch v := value.(type) {
+	case nil:
+		return Field{Key: key}
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int8:
+		return Int64(key, int64(v))
+	case int16:
+		return Int64(key, int64(v))
+	case int32:
+		return Int64(key, int64(v))
+	case int64:
+		return Int64(key, v)
+	case uint:
+		return Uint64(key, uint64(v))
+	case uint8:
+		return Uint64(key, uint64(v))
+	case uint16:
+		return Uint64(key, uint64(v))
+	case uint32:
+		return Uint64(key, uint64(v))
+	case uint64:
+		return Uint64(key, v)
+	case float32:
+		return Float64(key, float64(v))
+	case float64:
+		return Float64(key, v)
+	case bool:
+		return Bool(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case error:
+		return NamedErr(key, v)
+	default:
+		return Field{Key: key, kind: anyKind, any: v}
+	}
+}
+
+// Value returns the value of the Field as passed to its constructor, with
+// the exception of Int fields, which are returned as int64.
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case stringKind:
+		return f.str
+	case intKind:
+		return int64(f.num)
+	case uintKind:
+		return f.num
+	case floatKind:
+		return math.Float64frombits(f.num)
+	case boolKind:
+		return f.num == 1
+	case durationKind:
+		return time.Duration(f.num)
+	default:
+		return f.any
+	}
+}
+
+// IsNil reports whether the value of the Field is nil.
+func (f Field) IsNil() bool { return f.kind == nilKind }
+
+// String returns the value of the Field rendered as text. A nil value results
+// in an empty string.
+func (f Field) String() string { return string(f.appendText(nil)) }
+
+// appendText appends the value of the Field as text to buf.
+func (f Field) appendText(buf []byte) []byte {
+	switch f.kind {
+	case nilKind:
+		return buf
+	case stringKind:
+		return append(buf, f.str...)
+	case intKind:
+		return strconv.AppendInt(buf, int64(f.num), 10)
+	case uintKind:
+		return strconv.AppendUint(buf, f.num, 10)
+	case floatKind:
+		return strconv.AppendFloat(buf, math.Float64frombits(f.num), 'g', -1, 64)
+	case boolKind:
+		return strconv.AppendBool(buf, f.num == 1)
+	case durationKind:
+		return append(buf, time.Duration(f.num).String()...)
+	case timeKind:
+		return f.any.(time.Time).AppendFormat(buf, time.RFC3339Nano)
+	case errorKind:
+		return append(buf, callString(f.any, "Error", f.any.(error).Error)...)
+	default:
+		return append(buf, fmt.Sprint(f.any)...)
+	}
+}
+
+// callString returns the result of method, the Error or String method of v,
+// recovering from a panic in the manner of fmt: a nil pointer receiver results
+// in "<nil>" and other panics are reported in the result.
+func callString(v interface{}, name string, method func() string) (s string) {
+	defer func() {
+		if err := recover(); err != nil {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+				s = "<nil>"
+				return
+			}
+			s = fmt.Sprintf("%%!v(PANIC=%s method: %v)", name, err)
+		}
+	}()
+	return method()
+}
+
+// appendJSON appends the value of the Field to buf, keeping its JSON type
+// where possible.
+func (f Field) appendJSON(buf []byte) []byte {
+	switch f.kind {
+	case nilKind:
+		return append(buf, "null"...)
+	case intKind, uintKind, boolKind:
+		return f.appendText(buf)
+	case floatKind:
+		return appendJSONFloat(buf, math.Float64frombits(f.num), 64)
+	case anyKind:
+		return appendJSONValue(buf, f.any)
+	default:
+		return appendJSONString(buf, f.String())
+	}
+}
+
+// mapFields converts a map into fields ordered by key.
+func mapFields(kvs map[string]interface{}) []Field {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]Field, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, Any(key, kvs[key]))
+	}
+	return fields
+}
+
+// mergeFields returns the parent fields followed by the child fields, where a
+// child field replaces the value of a parent field with the same key. The
+// parent slice is not modified.
+func mergeFields(parent, child []Field) []Field {
+	merged := make([]Field, len(parent), len(parent)+len(child))
+	copy(merged, parent)
+Child:
+	for _, f := range child {
+		for i := range merged {
+			if merged[i].Key == f.Key {
+				merged[i] = f
+				continue Child
+			}
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// appendFieldTags appends fields as the "[key=value] " prefix of a message to
+// buf.
+func appendFieldTags(buf []byte, fields []Field) []byte {
+	for _, f := range fields {
+		buf = append(buf, '[')
+		buf = append(buf, f.Key...)
+		if !f.IsNil() {
+			buf = append(buf, '=')
+			buf = f.appendText(buf)
+		}
+		buf = append(buf, "] "...)
+	}
+	return buf
+}
+
+// fieldParams renders fields as structured data parameters. A nil value
+// results in an empty parameter value.
+func fieldParams(fields []Field) []slog.SDParam {
+	params := make([]slog.SDParam, 0, len(fields))
+	for _, f := range fields {
+		params = append(params, slog.SDParam{Name: f.Key, Value: f.String()})
+	}
+	return params
+}
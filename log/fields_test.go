@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerWithTypedFields(t *testing.T) {
+	fields := []log.Field{
+		log.String("z", "last"),
+		log.Int("count", -3),
+		log.Uint64("size", 42),
+		log.Float64("ratio", 0.25),
+		log.Bool("ok", false),
+		log.Duration("took", 1500*time.Millisecond),
+		log.Time("at", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+		log.ErrField(errors.New("boom")),
+		log.Any("none", nil),
+	}
+
+	tests := map[string]struct {
+		format log.OutputFormat
+		expect string
+	}{
+		"text": {
+			format: log.TextOutput,
+			expect: "[z=last] [count=-3] [size=42] [ratio=0.25] [ok=false] [took=1.5s] " +
+				"[at=2020-01-02T03:04:05Z] [error=boom] [none] hello\n",
+		},
+		"logfmt": {
+			format: log.LogfmtOutput,
+			expect: "msg=hello z=last count=-3 size=42 ratio=0.25 ok=false took=1.5s " +
+				"at=2020-01-02T03:04:05Z error=boom none\n",
+		},
+		"json": {
+			format: log.JSONOutput,
+			expect: `"message":"hello","z":"last","count":-3,"size":42,"ratio":0.25,"ok":false,"took":"1.5s",` +
+				`"at":"2020-01-02T03:04:05Z","error":"boom","none":null}` + "\n",
+		},
+	}
+
+	for desc, test := range tests {
+		var buf bytes.Buffer
+
+		logger := new(log.Logger)
+		logger.SetOutput(&buf)
+		logger.SetOutputFormat(test.format)
+
+		// Print twice to ensure the order is stable
+		p := logger.With(fields...)
+		p.Info("hello")
+		p.Info("hello")
+		for _, line := range strings.SplitAfter(buf.String(), "\n")[:2] {
+			if !strings.HasSuffix(line, test.expect) {
+				t.Errorf("[%s] expected %q to end with %q", desc, line, test.expect)
+			}
+		}
+	}
+}
+
+func TestLoggerWithFieldsOrder(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+
+	logger.WithFields(map[string]interface{}{"c": 3, "a": 1, "b": 2}).Info("hello")
+	if expect := "[a=1] [b=2] [c=3] hello\n"; !strings.HasSuffix(buf.String(), expect) {
+		t.Errorf("expected %q to end with %q", buf.String(), expect)
+	}
+}
+
+type ptrErr struct{ msg string }
+
+func (e *ptrErr) Error() string { return e.msg }
+
+type ptrStringer struct{ s string }
+
+func (p *ptrStringer) String() string { return p.s }
+
+func TestLoggerWithTypedNilFields(t *testing.T) {
+	for _, format := range []log.OutputFormat{log.TextOutput, log.LogfmtOutput, log.JSONOutput} {
+		var buf bytes.Buffer
+		logger := new(log.Logger)
+		logger.SetOutput(&buf)
+		logger.SetOutputFormat(format)
+
+		// Expect typed nils to be rendered like fmt does rather than panic
+		logger.WithField("err", (*ptrErr)(nil)).
+			WithField("str", (*ptrStringer)(nil)).
+			With(log.ErrField((*ptrErr)(nil))).
+			Info("x")
+		if strings.Count(buf.String(), "<nil>") != 3 {
+			t.Errorf("expected 3 nils in output %q", buf.String())
+		}
+	}
+}
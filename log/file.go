@@ -0,0 +1,361 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTime is the layout of the time a file was rotated in the name of its
+// backup.
+const backupTime = "20060102T150405.000"
+
+// backupSeq separates the time in the name of a backup from the sequence
+// number of backups rotated in the same millisecond.
+const backupSeq = "_"
+
+// FileOption configures a FileSink created by NewFileSink.
+type FileOption func(*fileConfig)
+
+type fileConfig struct {
+	maxSize    int64
+	every      time.Duration
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	enc        Encoder
+}
+
+// FileMaxSize rotates the file before a write would make it larger than n
+// bytes.
+func FileMaxSize(n int64) FileOption {
+	return func(c *fileConfig) { c.maxSize = n }
+}
+
+// FileRotateEvery rotates the file at each multiple of d since the zero time,
+// e.g. at midnight UTC for 24h.
+func FileRotateEvery(d time.Duration) FileOption {
+	return func(c *fileConfig) { c.every = d }
+}
+
+// FileMaxBackups removes the oldest rotated files beyond n.
+func FileMaxBackups(n int) FileOption {
+	return func(c *fileConfig) { c.maxBackups = n }
+}
+
+// FileMaxAge removes rotated files older than d, e.g. 7*24*time.Hour to keep
+// a week of logs.
+func FileMaxAge(d time.Duration) FileOption {
+	return func(c *fileConfig) { c.maxAge = d }
+}
+
+// FileCompress compresses rotated files with gzip.
+func FileCompress() FileOption {
+	return func(c *fileConfig) { c.compress = true }
+}
+
+// FileEncoder renders records written to the file with enc instead of a
+// TextEncoder.
+func FileEncoder(enc Encoder) FileOption {
+	return func(c *fileConfig) { c.enc = enc }
+}
+
+// FileSink is a Sink writing records to a file that is rotated by size or
+// time. A rotated file is renamed to a backup with the time of rotation
+// inserted before its extension, e.g. "app-20200102T150405.000.log", and
+// optionally compressed. Backups are removed by count or age.
+//
+// Reload reopens the file, e.g. after it is moved by logrotate, see
+// SignalReloads.
+type FileSink struct {
+	path string
+	cfg  fileConfig
+
+	mu     sync.Mutex
+	f      *os.File // nil if closed or reopening failed
+	closed bool
+	size   int64
+	rotate time.Time // next time-based rotation, zero if disabled
+
+	millMu sync.Mutex     // serializes compressing and removing backups
+	mills  sync.WaitGroup // waited on by Close
+}
+
+// NewFileSink opens a file sink appending to the file at path, creating it
+// and its directory if necessary.
+func NewFileSink(path string, opts ...FileOption) (*FileSink, error) {
+	s := &FileSink{path: path}
+	for _, opt := range opts {
+		opt(&s.cfg)
+	}
+	if s.cfg.enc == nil {
+		s.cfg.enc = TextEncoder{}
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Only call with a lock on the mutex, or before the sink is shared
+func (s *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("error creating log dir: %v", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+
+	s.f, s.size = f, info.Size()
+	if s.cfg.every > 0 {
+		start := time.Now().Truncate(s.cfg.every)
+		s.rotate = start.Add(s.cfg.every)
+		if s.size > 0 && info.ModTime().Before(start) {
+			// Written in an earlier period, so rotate on the next write
+			s.rotate = start
+		}
+	}
+	return nil
+}
+
+// WriteRecord implements Sink.
+func (s *FileSink) WriteRecord(r *Record) error {
+	line, err := s.cfg.enc.Encode(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	if (s.cfg.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.cfg.maxSize) ||
+		(!s.rotate.IsZero() && !time.Now().Before(s.rotate)) {
+		if err := s.rotateFile(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Rotate renames the file to a backup and opens a new file.
+func (s *FileSink) Rotate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	return s.rotateFile()
+}
+
+// Only call with a lock on the mutex
+func (s *FileSink) rotateFile() error {
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+
+	backup := s.backupPath(time.Now())
+	if err := os.Rename(s.path, backup); err != nil {
+		return fmt.Errorf("error renaming log file: %v", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	s.mills.Add(1)
+	go func() {
+		defer s.mills.Done()
+		s.mill(backup)
+	}()
+	return nil
+}
+
+// backupPath returns an unused name for a backup of the file rotated at t.
+// If the file was already rotated in the same millisecond, then a sequence
+// number is added after the time, e.g. "app-20200102T150405.000_1.log", so
+// that no backup is overwritten.
+func (s *FileSink) backupPath(t time.Time) string {
+	ext := filepath.Ext(s.path)
+	base := strings.TrimSuffix(s.path, ext) + "-" + t.UTC().Format(backupTime)
+	for seq := 0; ; seq++ {
+		backup := base + ext
+		if seq > 0 {
+			backup = base + backupSeq + strconv.Itoa(seq) + ext
+		}
+		if !fileExists(backup) && !fileExists(backup+".gz") {
+			return backup
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+// mill compresses a new backup, if enabled, and removes backups by count and
+// age.
+func (s *FileSink) mill(backup string) {
+	s.millMu.Lock()
+	defer s.millMu.Unlock()
+
+	if s.cfg.compress {
+		if err := compressFile(backup); err != nil {
+			printError("error compressing %s: %v", backup, err)
+		}
+	}
+	if s.cfg.maxBackups <= 0 && s.cfg.maxAge <= 0 {
+		return
+	}
+
+	backups, err := s.backups()
+	if err != nil {
+		printError("error listing log backups: %v", err)
+		return
+	}
+	for i, b := range backups {
+		if (s.cfg.maxBackups > 0 && i >= s.cfg.maxBackups) ||
+			(s.cfg.maxAge > 0 && time.Since(b.time) > s.cfg.maxAge) {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+type fileBackup struct {
+	path string
+	time time.Time
+	seq  int
+}
+
+// backups returns the backups of the file, newest first.
+func (s *FileSink) backups() ([]fileBackup, error) {
+	ext := filepath.Ext(s.path)
+	prefix := filepath.Base(strings.TrimSuffix(s.path, ext)) + "-"
+
+	infos, err := ioutil.ReadDir(filepath.Dir(s.path))
+	if err != nil {
+		return nil, err
+	}
+	var backups []fileBackup
+	for _, info := range infos {
+		name := info.Name()
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		if info.IsDir() || !strings.HasPrefix(stamp, prefix) {
+			continue
+		}
+		stamp = strings.TrimPrefix(stamp, prefix)
+		var seq int
+		if i := strings.Index(stamp, backupSeq); i >= 0 {
+			if seq, err = strconv.Atoi(stamp[i+len(backupSeq):]); err != nil {
+				continue
+			}
+			stamp = stamp[:i]
+		}
+		t, err := time.Parse(backupTime, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, fileBackup{path: filepath.Join(filepath.Dir(s.path), name), time: t, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.After(backups[j].time)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// compressFile replaces path with a gzip compressed copy with the ".gz"
+// extension.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Reload implements Reloader by reopening the file.
+func (s *FileSink) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// Only call with a lock on the mutex
+func (s *FileSink) closeFile() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return fmt.Errorf("error closing log file: %v", err)
+	}
+	return nil
+}
+
+// Close implements io.Closer, waiting for any backups to be compressed and
+// removed.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	s.closed = true
+	err := s.closeFile()
+	s.mills.Wait()
+	return err
+}
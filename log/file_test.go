@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestFileSinkRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	s, err := log.NewFileSink(path, log.FileMaxSize(10), log.FileMaxBackups(2), log.FileCompress(),
+		log.FileEncoder(log.EncoderFunc(func(r *log.Record) ([]byte, error) {
+			return []byte(r.Message + "\n"), nil
+		})))
+	if err != nil {
+		t.Fatalf("error opening file sink: %v", err)
+	}
+
+	// Expect each 6 byte line to rotate the file, keeping 2 backups
+	for _, msg := range []string{"line1", "line2", "line3", "line4"} {
+		if err := s.WriteRecord(&log.Record{Message: msg}); err != nil {
+			t.Fatalf("error writing record: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // for unique backup names
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("error closing file sink: %v", err)
+	}
+
+	if b, _ := ioutil.ReadFile(path); string(b) != "line4\n" {
+		t.Errorf("expected current file to contain line4, got %q", b)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 compressed backups, got %v", backups)
+	}
+	f, err := os.Open(backups[1])
+	if err != nil {
+		t.Fatalf("error opening backup: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("error reading backup: %v", err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != "line3\n" {
+		t.Errorf("expected newest backup to contain line3, got %q", b)
+	}
+}
+
+func TestFileSinkRotateRapidly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	s, err := log.NewFileSink(path, log.FileMaxSize(200),
+		log.FileEncoder(log.EncoderFunc(func(r *log.Record) ([]byte, error) {
+			return []byte(r.Message + "\n"), nil
+		})))
+	if err != nil {
+		t.Fatalf("error opening file sink: %v", err)
+	}
+
+	// Expect no backup to be overwritten by rotations in the same millisecond
+	for i := 0; i < 100; i++ {
+		if err := s.WriteRecord(&log.Record{Message: fmt.Sprintf("line %014d", i)}); err != nil {
+			t.Fatalf("error writing record: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("error closing file sink: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "app*.log"))
+	var lines int
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", file, err)
+		}
+		lines += strings.Count(string(b), "\n")
+	}
+	if lines != 100 {
+		t.Errorf("expected 100 lines in %d files, got %d", len(files), lines)
+	}
+}
+
+func TestFileSinkRotateEvery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	s, err := log.NewFileSink(path, log.FileRotateEvery(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error opening file sink: %v", err)
+	}
+	defer s.Close()
+
+	_ = s.WriteRecord(&log.Record{Message: "first"})
+	time.Sleep(60 * time.Millisecond)
+	_ = s.WriteRecord(&log.Record{Message: "second"})
+
+	if b, _ := ioutil.ReadFile(path); strings.Contains(string(b), "first") {
+		t.Errorf("expected file to be rotated, got %q", b)
+	}
+	if backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log")); len(backups) != 1 {
+		t.Errorf("expected 1 backup, got %v", backups)
+	}
+}
+
+func TestFileSinkReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	s, err := log.NewFileSink(path)
+	if err != nil {
+		t.Fatalf("error opening file sink: %v", err)
+	}
+	logger := new(log.Logger)
+	if err := logger.AddSink("file", s, log.LevelInherit); err != nil {
+		t.Fatalf("error adding file sink: %v", err)
+	}
+	defer func() { _ = logger.RemoveSink("file") }()
+
+	// Move the file as logrotate does and expect a new one after reloading
+	logger.Info("before")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("error moving log file: %v", err)
+	}
+	if err := logger.Reload(); err != nil {
+		t.Fatalf("error reloading: %v", err)
+	}
+	logger.Info("after")
+
+	if b, _ := ioutil.ReadFile(path + ".1"); !strings.HasSuffix(string(b), "before\n") {
+		t.Errorf("expected moved file to end with before, got %q", b)
+	}
+	if b, _ := ioutil.ReadFile(path); !strings.HasSuffix(string(b), "after\n") || strings.Contains(string(b), "before") {
+		t.Errorf("expected new file to contain only after, got %q", b)
+	}
+}
@@ -1,3 +1,9 @@
 module github.com/open-ness/common/log
 
 go 1.14
+
+require (
+	github.com/golang/protobuf v1.4.2
+	google.golang.org/grpc v1.29.1
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/syslog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Keys of the fields of logs written by GrpcInterceptors.
+const (
+	// GrpcMethodKey is the key of the field holding the full method of an
+	// RPC, e.g. "/grpc.health.v1.Health/Check".
+	GrpcMethodKey = "grpc.method"
+	// GrpcPeerKey is the key of the field holding the address of the client
+	// of an RPC served or the target of an RPC called.
+	GrpcPeerKey = "grpc.peer"
+	// GrpcKindKey is the key of the field holding whether an RPC was served
+	// or called and whether it was unary or streaming, e.g. "server_unary".
+	GrpcKindKey = "grpc.kind"
+	// GrpcCodeKey is the key of the field holding the status code of an RPC,
+	// e.g. "NotFound".
+	GrpcCodeKey = "grpc.code"
+	// GrpcDurationKey is the key of the field holding the duration of an RPC.
+	GrpcDurationKey = "grpc.duration"
+	// GrpcRequestSizeKey is the key of the field holding the total size in
+	// bytes of the request messages of an RPC.
+	GrpcRequestSizeKey = "grpc.request_size"
+	// GrpcResponseSizeKey is the key of the field holding the total size in
+	// bytes of the response messages of an RPC.
+	GrpcResponseSizeKey = "grpc.response_size"
+	// GrpcPayloadKey is the key of the field holding a message logged by
+	// GrpcPayloads.
+	GrpcPayloadKey = "grpc.payload"
+)
+
+// GrpcOption configures GrpcInterceptors.
+type GrpcOption func(*grpcConfig)
+
+type grpcConfig struct {
+	levels      func(codes.Code) syslog.Priority
+	payloadSize int
+	sizes       bool
+}
+
+// GrpcCodeLevels chooses the severity of the log of an RPC from its status
+// code with f instead of GrpcCodeLevel.
+func GrpcCodeLevels(f func(codes.Code) syslog.Priority) GrpcOption {
+	return func(c *grpcConfig) { c.levels = f }
+}
+
+// GrpcPayloads logs each request and response message at DEBUG, rendered as
+// protobuf text and cut after maxSize bytes, which is marked by "...".
+// Messages are not rendered unless DEBUG is enabled.
+func GrpcPayloads(maxSize int) GrpcOption {
+	return func(c *grpcConfig) { c.payloadSize = maxSize }
+}
+
+// GrpcSizes tags the log of each RPC with the total sizes of its request and
+// response messages. Each protobuf message is encoded again to size it, so
+// this adds to the cost of every RPC.
+func GrpcSizes() GrpcOption {
+	return func(c *grpcConfig) { c.sizes = true }
+}
+
+// GrpcCodeLevel returns the default severity of the log of an RPC with a
+// status code: INFO for success and errors caused by the client, WARNING for
+// errors that may be transient or caused by the state of the system and ERR
+// for errors of the server.
+func GrpcCodeLevel(code codes.Code) syslog.Priority {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound,
+		codes.AlreadyExists, codes.Unauthenticated:
+		return syslog.LOG_INFO
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return syslog.LOG_WARNING
+	default:
+		return syslog.LOG_ERR
+	}
+}
+
+// GrpcInterceptors provides gRPC server and client interceptors writing one
+// log per RPC when it finishes, tagged with its method, peer, kind, status
+// code, duration and, with GrpcSizes, the sizes of its requests and responses.
+// The severity of the log is chosen from the status code, see GrpcCodeLevel.
+// Its message is "finished call" followed by the method, so that Sampling
+// counts the logs of each method apart.
+//
+// Each RPC gets a Printer tagged with its method and peer, which is attached
+// to the context of the RPC and retrieved with FromContext, e.g. by the
+// handlers of a server.
+//
+// Sizes are only counted for protobuf messages.
+type GrpcInterceptors struct {
+	printer Printer
+	config  grpcConfig
+}
+
+// GrpcInterceptors returns interceptors logging RPCs with the Logger, fields
+// and component of p.
+func (p Printer) GrpcInterceptors(opts ...GrpcOption) *GrpcInterceptors {
+	if p.logger == nil {
+		p = DefaultLogger.printer(p.component, p.fields)
+	}
+	i := &GrpcInterceptors{
+		printer: p,
+		config:  grpcConfig{levels: GrpcCodeLevel},
+	}
+	for _, opt := range opts {
+		opt(&i.config)
+	}
+	return i
+}
+
+// GrpcInterceptors returns interceptors logging RPCs with l.
+func (l *Logger) GrpcInterceptors(opts ...GrpcOption) *GrpcInterceptors {
+	l.once.Do(l.initPrinter)
+	return l.Printer.GrpcInterceptors(opts...)
+}
+
+// UnaryServer returns an interceptor logging unary RPCs served, e.g. to pass
+// to grpc.UnaryInterceptor.
+func (i *GrpcInterceptors) UnaryServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		call := i.newCall("server_unary", info.FullMethod, serverPeer(ctx))
+		call.request(req)
+		resp, err := handler(NewContext(ctx, call.printer), req)
+		if err == nil {
+			call.response(resp)
+		}
+		call.finish(err)
+		return resp, err
+	}
+}
+
+// StreamServer returns an interceptor logging streaming RPCs served, e.g. to
+// pass to grpc.StreamInterceptor.
+func (i *GrpcInterceptors) StreamServer() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		call := i.newCall("server_stream", info.FullMethod, serverPeer(ss.Context()))
+		err := handler(srv, &grpcServerStream{
+			ServerStream: ss,
+			ctx:          NewContext(ss.Context(), call.printer),
+			call:         call,
+		})
+		call.finish(err)
+		return err
+	}
+}
+
+// UnaryClient returns an interceptor logging unary RPCs called, e.g. to pass
+// to grpc.WithUnaryInterceptor.
+func (i *GrpcInterceptors) UnaryClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		call := i.newCall("client_unary", method, cc.Target())
+		call.request(req)
+		err := invoker(NewContext(ctx, call.printer), method, req, reply, cc, opts...)
+		if err == nil {
+			call.response(reply)
+		}
+		call.finish(err)
+		return err
+	}
+}
+
+// StreamClient returns an interceptor logging streaming RPCs called, e.g. to
+// pass to grpc.WithStreamInterceptor. An RPC is logged when receiving from
+// its stream fails or returns io.EOF or, if the server does not stream, the
+// response, or when its context is done, e.g. if the client stops receiving
+// and cancels it.
+func (i *GrpcInterceptors) StreamClient() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+		method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		call := i.newCall("client_stream", method, cc.Target())
+		cs, err := streamer(NewContext(ctx, call.printer), desc, cc, method, opts...)
+		if err != nil {
+			call.finish(err)
+			return nil, err
+		}
+		call.done = make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				call.finish(status.FromContextError(ctx.Err()).Err())
+			case <-call.done:
+			}
+		}()
+		return &grpcClientStream{ClientStream: cs, desc: desc, call: call}, nil
+	}
+}
+
+// grpcCall is an RPC being logged.
+type grpcCall struct {
+	// First for 64-bit alignment of atomic accesses on 32-bit platforms
+	requestSize  int64
+	responseSize int64
+
+	config  *grpcConfig
+	printer Printer
+	method  string
+	kind    string
+	start   time.Time
+	once    sync.Once
+	done    chan struct{} // closed by finish, if not nil
+}
+
+func (i *GrpcInterceptors) newCall(kind, method, peer string) *grpcCall {
+	fields := []Field{String(GrpcMethodKey, method)}
+	if peer != "" {
+		fields = append(fields, String(GrpcPeerKey, peer))
+	}
+	return &grpcCall{
+		config:  &i.config,
+		printer: i.printer.With(fields...),
+		method:  method,
+		kind:    kind,
+		start:   time.Now(),
+	}
+}
+
+// request counts a request message and logs its payload if enabled.
+func (c *grpcCall) request(m interface{}) {
+	if c.config.sizes {
+		atomic.AddInt64(&c.requestSize, messageSize(m))
+	}
+	c.payload("request", m)
+}
+
+// response counts a response message and logs its payload if enabled.
+func (c *grpcCall) response(m interface{}) {
+	if c.config.sizes {
+		atomic.AddInt64(&c.responseSize, messageSize(m))
+	}
+	c.payload("response", m)
+}
+
+func (c *grpcCall) payload(msg string, m interface{}) {
+	p := c.printer
+	if c.config.payloadSize <= 0 || !p.logger.enabled(syslog.LOG_DEBUG, p.patterns) {
+		return
+	}
+	text := payloadText(m)
+	if len(text) > c.config.payloadSize {
+		n := c.config.payloadSize
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n] + "..."
+	}
+	p.With(String(GrpcPayloadKey, text)).Debug(msg)
+}
+
+// finish logs the RPC with the status of err, once.
+func (c *grpcCall) finish(err error) {
+	c.once.Do(func() {
+		if c.done != nil {
+			close(c.done)
+		}
+		code := status.Code(err)
+		fields := []Field{
+			String(GrpcKindKey, c.kind),
+			String(GrpcCodeKey, code.String()),
+			Duration(GrpcDurationKey, time.Since(c.start)),
+		}
+		if c.config.sizes {
+			fields = append(fields,
+				Int64(GrpcRequestSizeKey, atomic.LoadInt64(&c.requestSize)),
+				Int64(GrpcResponseSizeKey, atomic.LoadInt64(&c.responseSize)))
+		}
+		if err != nil {
+			fields = append(fields, ErrField(err))
+		}
+		c.printer.With(fields...).Print(c.config.levels(code), "finished call "+c.method)
+	})
+}
+
+// grpcServerStream is a grpc.ServerStream counting the messages of a call
+// and carrying its Printer in its context.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	call *grpcCall
+}
+
+func (s *grpcServerStream) Context() context.Context { return s.ctx }
+
+func (s *grpcServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.response(m)
+	}
+	return err
+}
+
+func (s *grpcServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.request(m)
+	}
+	return err
+}
+
+// grpcClientStream is a grpc.ClientStream counting the messages of a call
+// and logging it when it ends.
+type grpcClientStream struct {
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+	call *grpcCall
+}
+
+func (s *grpcClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.request(m)
+	}
+	return err
+}
+
+func (s *grpcClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.call.finish(nil)
+	case err != nil:
+		s.call.finish(err)
+	default:
+		s.call.response(m)
+		if !s.desc.ServerStreams {
+			s.call.finish(nil)
+		}
+	}
+	return err
+}
+
+// serverPeer returns the address of the client of an RPC served with ctx.
+func serverPeer(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// messageSize returns the encoded size of a protobuf message or 0 for other
+// messages.
+func messageSize(m interface{}) int64 {
+	if pm, ok := m.(proto.Message); ok {
+		return int64(proto.Size(pm))
+	}
+	return 0
+}
+
+// payloadText renders a message as protobuf text if it is one.
+func payloadText(m interface{}) string {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.CompactTextString(pm)
+	}
+	return fmt.Sprint(m)
+}
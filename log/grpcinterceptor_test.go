@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func expectContains(t *testing.T, out string, expect ...string) {
+	t.Helper()
+	for _, s := range expect {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output %q", s, out)
+		}
+	}
+}
+
+func TestGrpcInterceptorsUnaryServer(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	interceptor := logger.Component("api").GrpcInterceptors(log.GrpcSizes()).UnaryServer()
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	req := &healthpb.HealthCheckRequest{Service: "proxy"}
+	_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		log.FromContext(ctx).Info("in handler")
+		return nil, status.Error(codes.Unavailable, "overloaded")
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected error of handler, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 logs, got %q", buf.String())
+	}
+	expectContains(t, lines[0], "[component=api] [grpc.method=/grpc.health.v1.Health/Check] "+
+		"[grpc.peer=127.0.0.1:1234] in handler")
+	expectContains(t, lines[1], "<132>", "[grpc.method=/grpc.health.v1.Health/Check]",
+		"[grpc.kind=server_unary]", "[grpc.code=Unavailable]", "[grpc.duration=",
+		"[grpc.request_size=7]", "[grpc.response_size=0]", "finished call")
+}
+
+func TestGrpcInterceptorsPayloads(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	interceptor := logger.GrpcInterceptors(log.GrpcPayloads(12), log.GrpcSizes()).UnaryServer()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	req := &healthpb.HealthCheckRequest{Service: "a long service name"}
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}
+
+	// Expect no payloads unless at DEBUG
+	_, _ = interceptor(context.Background(), req, info, handler)
+	if strings.Contains(buf.String(), "grpc.payload") {
+		t.Errorf("expected no payload in output %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.SetLevel(syslog.LOG_DEBUG)
+	_, _ = interceptor(context.Background(), req, info, handler)
+	expectContains(t, buf.String(),
+		`[grpc.payload=service:"a l...] request`,
+		`[grpc.payload=status:SERVI...] response`,
+		"[grpc.code=OK]", "[grpc.request_size=21] [grpc.response_size=2]")
+}
+
+func TestGrpcInterceptorsCodeLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetLevel(syslog.LOG_WARNING)
+	interceptor := logger.GrpcInterceptors(log.GrpcCodeLevels(func(code codes.Code) syslog.Priority {
+		if code == codes.NotFound {
+			return syslog.LOG_ERR
+		}
+		return log.GrpcCodeLevel(code)
+	})).UnaryServer()
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Get"}
+
+	for _, code := range []codes.Code{codes.OK, codes.NotFound} {
+		_, _ = interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, status.Error(code, "")
+		})
+	}
+	if out := buf.String(); strings.Contains(out, "[grpc.code=OK]") ||
+		!strings.Contains(out, "[grpc.code=NotFound]") {
+		t.Errorf("expected only NotFound call in output %q", out)
+	}
+	if out := buf.String(); strings.Contains(out, "grpc.request_size") {
+		t.Errorf("expected no sizes without GrpcSizes in output %q", out)
+	}
+}
+
+func TestGrpcInterceptorsSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetSampling(log.Sampling{Interval: time.Hour, First: 1})
+	defer logger.SetSampling(log.Sampling{})
+	interceptor := logger.GrpcInterceptors().UnaryServer()
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	// Expect the calls of each method to be sampled apart
+	for _, method := range []string{"/svc/Get", "/svc/Get", "/svc/Put", "/svc/Put"} {
+		_, _ = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+	if out := buf.String(); strings.Count(out, "\n") != 2 {
+		t.Errorf("expected one log per method in output %q", out)
+	}
+	expectContains(t, buf.String(), "finished call /svc/Get", "finished call /svc/Put")
+}
+
+func TestGrpcInterceptorsClient(t *testing.T) {
+	var serverBuf, clientBuf syncBuffer
+	serverLogger, clientLogger := new(log.Logger), new(log.Logger)
+	serverLogger.SetOutput(&serverBuf)
+	clientLogger.SetOutput(&clientBuf)
+
+	serverInterceptors := serverLogger.GrpcInterceptors(log.GrpcSizes())
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(serverInterceptors.UnaryServer()),
+		grpc.StreamInterceptor(serverInterceptors.StreamServer()))
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("proxy", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	lis := bufconn.Listen(1 << 16)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	clientInterceptors := clientLogger.GrpcInterceptors(log.GrpcSizes())
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithUnaryInterceptor(clientInterceptors.UnaryClient()),
+		grpc.WithStreamInterceptor(clientInterceptors.StreamClient()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	// Expect unary calls to be logged by both sides
+	if _, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	expectContains(t, clientBuf.String(), "[grpc.method=/grpc.health.v1.Health/Check] [grpc.peer=bufnet]",
+		"[grpc.kind=client_unary]", "[grpc.code=NotFound]", "[grpc.request_size=9]")
+	expectContains(t, serverBuf.String(), "[grpc.method=/grpc.health.v1.Health/Check] [grpc.peer=bufconn]",
+		"[grpc.kind=server_unary]", "[grpc.code=NotFound]", "[grpc.request_size=9]")
+
+	// Expect a stream to be logged by the client when it ends
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "proxy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(clientBuf.String(), "client_stream") {
+		t.Errorf("expected stream not logged before it ends in output %q", clientBuf.String())
+	}
+	cancel()
+	if _, err = stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	expectContains(t, clientBuf.String(), "[grpc.method=/grpc.health.v1.Health/Watch]",
+		"[grpc.kind=client_stream]", "[grpc.code=Canceled]", "[grpc.request_size=7] [grpc.response_size=2]")
+
+	// Expect a stream to be logged when its context is canceled without
+	// receiving again
+	var canceledBuf syncBuffer
+	clientLogger.SetOutput(&canceledBuf)
+	ctx, cancel = context.WithCancel(context.Background())
+	if stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "proxy"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for start := time.Now(); !strings.Contains(canceledBuf.String(), "client_stream"); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("expected canceled stream to be logged in output %q", canceledBuf.String())
+		}
+	}
+	expectContains(t, canceledBuf.String(), "[grpc.kind=client_stream]", "[grpc.code=Canceled]")
+}
@@ -6,10 +6,41 @@ package log
 import (
 	"log/syslog"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// GrpcComponent is the component of logs written by a GrpcLogger. Its level
+// can be set like that of any other component, e.g. with
+// SetComponentLevel(GrpcComponent, syslog.LOG_WARNING).
+const GrpcComponent = "grpc"
+
+// Environment variables read by grpclog's own logger and honored by
+// GrpcLogger.
+const (
+	grpcSeverityEnv  = "GRPC_GO_LOG_SEVERITY_LEVEL"
+	grpcVerbosityEnv = "GRPC_GO_LOG_VERBOSITY_LEVEL"
+)
+
+var (
+	_ grpclog.Logger        = (*GrpcLogger)(nil)
+	_ grpclog.LoggerV2      = (*GrpcLogger)(nil)
+	_ grpclog.DepthLoggerV2 = (*GrpcLogger)(nil)
 )
 
-// GrpcLogger implements grpclog's Logger and LoggerV2 interfaces.
+// GrpcLogger implements grpclog's Logger, LoggerV2 and DepthLoggerV2
+// interfaces. Logs are written as the component GrpcComponent.
+//
+// Like grpclog's own logger, it honors the GRPC_GO_LOG_SEVERITY_LEVEL and
+// GRPC_GO_LOG_VERBOSITY_LEVEL environment variables, read when it is first
+// used. Logs less severe than the severity level, "info", "warning" or
+// "error", are dropped regardless of the level of the component. If it is not
+// set, then only errors are written, as by grpclog's own logger. If the
+// verbosity level is set, then V reports whether a level is at or below it
+// rather than deriving the verbosity from the level of the component.
 type GrpcLogger struct {
 	// Logger is the underlying Logger to write to. If none is specified, then
 	// the default logger (package var) is used.
@@ -19,7 +50,18 @@ type GrpcLogger struct {
 	// at. If none is specified, the default of INFO will be used.
 	PrintLevel syslog.Priority
 
-	once sync.Once
+	once         sync.Once
+	printer      Printer
+	severity     syslog.Priority // least severe level written
+	verbosity    int
+	verbositySet bool
+}
+
+// InstallGrpcLogger sets a GrpcLogger writing to l as the logger of grpclog,
+// which must be done before any gRPC functions are called. If l is nil, then
+// the default logger (package var) is used.
+func InstallGrpcLogger(l *Logger) {
+	grpclog.SetLoggerV2(&GrpcLogger{Logger: l})
 }
 
 func (l *GrpcLogger) init() {
@@ -29,6 +71,35 @@ func (l *GrpcLogger) init() {
 	if l.PrintLevel == 0 {
 		l.PrintLevel = syslog.LOG_INFO
 	}
+	l.printer = l.Logger.Component(GrpcComponent)
+
+	l.severity = syslog.LOG_ERR
+	switch strings.ToLower(os.Getenv(grpcSeverityEnv)) {
+	case "info":
+		l.severity = syslog.LOG_INFO
+	case "warning":
+		l.severity = syslog.LOG_WARNING
+	}
+	if v, err := strconv.Atoi(os.Getenv(grpcVerbosityEnv)); err == nil {
+		l.verbosity, l.verbositySet = v, true
+	}
+}
+
+// grpcPrintLevel is passed to printDepth for PrintLevel, which is set by init.
+const grpcPrintLevel syslog.Priority = -1
+
+// printDepth initializes and logs with severity lvl unless it is less severe
+// than GRPC_GO_LOG_SEVERITY_LEVEL. The caller is depth frames above the
+// caller of the GrpcLogger method.
+func (l *GrpcLogger) printDepth(depth int, lvl syslog.Priority, format string, args ...interface{}) {
+	l.once.Do(l.init)
+	if lvl == grpcPrintLevel {
+		lvl = l.PrintLevel
+	}
+	if lvl&severityMask > l.severity {
+		return
+	}
+	l.printer.printfDepth(depth, lvl, format, args...)
 }
 
 // Print logs to the level set at init. Arguments are handled in the manner
@@ -36,8 +107,7 @@ func (l *GrpcLogger) init() {
 //
 // This function partially implements the grpclog.Logger interface.
 func (l *GrpcLogger) Print(args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Print(l.PrintLevel, args...)
+	l.printDepth(0, grpcPrintLevel, "", args...)
 }
 
 // Println logs to the level set at init. Arguments are handled in the
@@ -53,16 +123,14 @@ func (l *GrpcLogger) Println(args ...interface{}) {
 //
 // This function partially implements the grpclog.Logger interface.
 func (l *GrpcLogger) Printf(format string, args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Printf(l.PrintLevel, format, args...)
+	l.printDepth(0, grpcPrintLevel, format, args...)
 }
 
 // Info initializes and logs to info logger. All arguments are forwarded.
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) Info(args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Info(args...)
+	l.printDepth(0, syslog.LOG_INFO, "", args...)
 }
 
 // Infoln logs to info logger. All arguments are forwarded to Info func
@@ -74,16 +142,22 @@ func (l *GrpcLogger) Infoln(args ...interface{}) { l.Info(args...) }
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) Infof(format string, args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Infof(format, args...)
+	l.printDepth(0, syslog.LOG_INFO, format, args...)
+}
+
+// InfoDepth logs to info logger with the caller depth frames up the stack.
+// Arguments are handled in the manner of fmt.Print.
+//
+// This function partially implements the grpclog.DepthLoggerV2 interface.
+func (l *GrpcLogger) InfoDepth(depth int, args ...interface{}) {
+	l.printDepth(depth, syslog.LOG_INFO, "", args...)
 }
 
 // Warning initializes and logs to warning logger. All arguments are forwarded.
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) Warning(args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Warning(args...)
+	l.printDepth(0, syslog.LOG_WARNING, "", args...)
 }
 
 // Warningln logs to warning logger. Arguments are forwarded to Warning func
@@ -95,16 +169,22 @@ func (l *GrpcLogger) Warningln(args ...interface{}) { l.Warning(args...) }
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) Warningf(format string, args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Warningf(format, args...)
+	l.printDepth(0, syslog.LOG_WARNING, format, args...)
+}
+
+// WarningDepth logs to warning logger with the caller depth frames up the
+// stack. Arguments are handled in the manner of fmt.Print.
+//
+// This function partially implements the grpclog.DepthLoggerV2 interface.
+func (l *GrpcLogger) WarningDepth(depth int, args ...interface{}) {
+	l.printDepth(depth, syslog.LOG_WARNING, "", args...)
 }
 
 // Error initializes and logs to error logger. All arguments are forwarded.
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) Error(args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Err(args...)
+	l.printDepth(0, syslog.LOG_ERR, "", args...)
 }
 
 // Errorln logs to error logger.
@@ -117,8 +197,15 @@ func (l *GrpcLogger) Errorln(args ...interface{}) { l.Error(args...) }
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) Errorf(format string, args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Errf(format, args...)
+	l.printDepth(0, syslog.LOG_ERR, format, args...)
+}
+
+// ErrorDepth logs to error logger with the caller depth frames up the stack.
+// Arguments are handled in the manner of fmt.Print.
+//
+// This function partially implements the grpclog.DepthLoggerV2 interface.
+func (l *GrpcLogger) ErrorDepth(depth int, args ...interface{}) {
+	l.printDepth(depth, syslog.LOG_ERR, "", args...)
 }
 
 // Fatal initializes, logs to alert logger and
@@ -127,8 +214,7 @@ func (l *GrpcLogger) Errorf(format string, args ...interface{}) {
 // This function partially implements the grpclog.Logger and grpclog.LoggerV2
 // interfaces.
 func (l *GrpcLogger) Fatal(args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Alert(args...)
+	l.printDepth(0, syslog.LOG_ALERT, "", args...)
 	os.Exit(1)
 }
 
@@ -144,31 +230,48 @@ func (l *GrpcLogger) Fatalln(args ...interface{}) { l.Fatal(args...) }
 // This function partially implements the grpclog.Logger and grpclog.LoggerV2
 // interfaces.
 func (l *GrpcLogger) Fatalf(format string, args ...interface{}) {
-	l.once.Do(l.init)
-	l.Logger.Alertf(format, args...)
+	l.printDepth(0, syslog.LOG_ALERT, format, args...)
+	os.Exit(1)
+}
+
+// FatalDepth logs to alert logger with the caller depth frames up the stack
+// and calls os.Exit with value 1. Arguments are handled in the manner of
+// fmt.Print.
+//
+// This function partially implements the grpclog.DepthLoggerV2 interface.
+func (l *GrpcLogger) FatalDepth(depth int, args ...interface{}) {
+	l.printDepth(depth, syslog.LOG_ALERT, "", args...)
 	os.Exit(1)
 }
 
 // V reports whether verbosity level l is at least the requested verbose level.
 //
-// Levels are _not_ identical to Syslog and are defined by the grpclog library
-// as:
+// If GRPC_GO_LOG_VERBOSITY_LEVEL is set, then levels at or below it are
+// enabled. Otherwise levels are derived from the level of GrpcComponent,
+// noting that they are _not_ identical to Syslog and are defined by the
+// grpclog library as:
 //
-//     0: FATAL and ERROR
-//     1: FATAL and ERROR and WARNING
-//     2: FATAL and ERROR and WARNING and INFO
+//	0: FATAL and ERROR
+//	1: FATAL and ERROR and WARNING
+//	2: FATAL and ERROR and WARNING and INFO
+//
+// Higher levels are only enabled at DEBUG.
 //
 // This function partially implements the grpclog.LoggerV2 interface.
 func (l *GrpcLogger) V(level int) bool {
 	l.once.Do(l.init)
-	switch level {
-	case 0:
-		return l.Logger.GetLevel() >= syslog.LOG_ERR
-	case 1:
-		return l.Logger.GetLevel() >= syslog.LOG_WARNING
-	case 2:
-		return l.Logger.GetLevel() >= syslog.LOG_INFO
+	if l.verbositySet {
+		return level <= l.verbosity
+	}
+	lvl := l.Logger.GetComponentLevel(GrpcComponent)
+	switch {
+	case level <= 0:
+		return lvl >= syslog.LOG_ERR
+	case level == 1:
+		return lvl >= syslog.LOG_WARNING
+	case level == 2:
+		return lvl >= syslog.LOG_INFO
 	default:
-		return false
+		return lvl >= syslog.LOG_DEBUG
 	}
 }
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/open-ness/common/log"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestGrpcLoggerComponent(t *testing.T) {
+	defer os.Unsetenv("GRPC_GO_LOG_SEVERITY_LEVEL")
+	os.Setenv("GRPC_GO_LOG_SEVERITY_LEVEL", "info")
+
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetLevel(syslog.LOG_DEBUG)
+	logger.SetComponentLevel(log.GrpcComponent, syslog.LOG_WARNING)
+	grpcLogger := &log.GrpcLogger{Logger: logger}
+
+	grpcLogger.Info("dropped")
+	grpcLogger.Warningf("%s", "written")
+	if out := buf.String(); strings.Contains(out, "dropped") ||
+		!strings.Contains(out, "[component=grpc] written") {
+		t.Errorf("expected only warning tagged with grpc component in output %q", out)
+	}
+
+	// Expect verbosity to follow the level of the component
+	for lvl, expect := range map[int]bool{0: true, 1: true, 2: false, 3: false} {
+		if v := grpcLogger.V(lvl); v != expect {
+			t.Errorf("expected V(%d) to be %t, got %t", lvl, expect, v)
+		}
+	}
+}
+
+func TestGrpcLoggerEnv(t *testing.T) {
+	defer os.Unsetenv("GRPC_GO_LOG_SEVERITY_LEVEL")
+	defer os.Unsetenv("GRPC_GO_LOG_VERBOSITY_LEVEL")
+	os.Setenv("GRPC_GO_LOG_SEVERITY_LEVEL", "error")
+	os.Setenv("GRPC_GO_LOG_VERBOSITY_LEVEL", "3")
+
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetLevel(syslog.LOG_DEBUG)
+	grpcLogger := &log.GrpcLogger{Logger: logger}
+
+	grpcLogger.Warning("dropped")
+	grpcLogger.Error("written")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "written") {
+		t.Errorf("expected only error in output %q", out)
+	}
+	if !grpcLogger.V(3) || grpcLogger.V(4) {
+		t.Errorf("expected verbosity level 3")
+	}
+}
+
+func TestGrpcLoggerDefaultSeverity(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetLevel(syslog.LOG_DEBUG)
+	grpcLogger := &log.GrpcLogger{Logger: logger}
+
+	// Expect only errors like grpclog's own logger
+	grpcLogger.Print("dropped")
+	grpcLogger.Warning("dropped")
+	grpcLogger.Error("written")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "written") {
+		t.Errorf("expected only error in output %q", out)
+	}
+}
+
+func TestGrpcLoggerPrintLevel(t *testing.T) {
+	defer os.Unsetenv("GRPC_GO_LOG_SEVERITY_LEVEL")
+	os.Setenv("GRPC_GO_LOG_SEVERITY_LEVEL", "info")
+
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	grpcLogger := &log.GrpcLogger{Logger: logger}
+
+	// Expect the first print to be written at the default print level
+	grpcLogger.Printf("%s", "printed")
+	if out := buf.String(); !strings.HasPrefix(out, fmt.Sprintf("<%d>", syslog.LOG_INFO|syslog.LOG_LOCAL0)) {
+		t.Errorf("expected print at INFO in output %q", out)
+	}
+}
+
+func TestGrpcLoggerDepth(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetCaller(true)
+	grpcLogger := &log.GrpcLogger{Logger: logger}
+
+	// Expect the caller of the func logging on its behalf
+	logFor := func() { grpcLogger.ErrorDepth(1, "msg") }
+	logFor()
+	expect := fmt.Sprintf("[caller=log/grpclogger_test.go:%d] [func=log_test.TestGrpcLoggerDepth]", line()-1)
+	if !strings.Contains(buf.String(), expect) {
+		t.Errorf("expected %q in output %q", expect, buf.String())
+	}
+}
+
+// grpcLog is the output of the logger installed for grpclog, which must be
+// done before any gRPC functions are called.
+var grpcLog syncBuffer
+
+func init() {
+	logger := new(log.Logger)
+	logger.SetOutput(&grpcLog)
+	log.InstallGrpcLogger(logger)
+}
+
+func TestInstallGrpcLogger(t *testing.T) {
+	grpclog.Errorf("%s", "installed")
+	if !strings.Contains(grpcLog.String(), "[component=grpc] installed") {
+		t.Errorf("expected log in output %q", grpcLog.String())
+	}
+}
@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler is an http.Handler for viewing and changing the levels of a Logger
+// at runtime. GET responds with the levels as JSON:
+//
+//	{
+//	  "level": "info",
+//	  "facility": "local0",
+//	  "components": {"proxy.*": "debug"},
+//	  "sinks": [{"name": "output", "level": "inherit"}, {"name": "syslog", "level": "debug"}]
+//	}
+//
+// PUT takes the same JSON, where omitted values are left unchanged, component
+// levels are merged with those set and a component level of null unsets it.
+// Sink levels are changed by name. A "revert" duration, e.g. "10m", restores
+// the levels before the PUT once it has passed, unless changed by another PUT
+// meanwhile. The time of a pending revert is included as "revert_at".
+type Handler struct {
+	logger *Logger
+
+	mu       sync.Mutex
+	timer    *time.Timer // pending revert
+	revision uint64      // of the levels set by PUT
+	saved    levelState  // restored by the pending revert
+	revertAt time.Time
+}
+
+// NewHandler returns a Handler for l. If l is nil, then DefaultLogger is
+// used.
+func NewHandler(l *Logger) *Handler {
+	if l == nil {
+		l = DefaultLogger
+	}
+	return &Handler{logger: l}
+}
+
+type handlerSink struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+}
+
+type handlerState struct {
+	Level      string            `json:"level"`
+	Facility   string            `json:"facility"`
+	Components map[string]string `json:"components"`
+	Sinks      []handlerSink     `json:"sinks"`
+	RevertAt   *time.Time        `json:"revert_at,omitempty"`
+}
+
+type handlerUpdate struct {
+	Level      *string            `json:"level"`
+	Facility   *string            `json:"facility"`
+	Components map[string]*string `json:"components"`
+	Sinks      []handlerSink      `json:"sinks"`
+	Revert     string             `json:"revert"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if err := h.update(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	state := h.logger.levelState().marshal()
+	if h.timer != nil {
+		revertAt := h.revertAt
+		state.RevertAt = &revertAt
+	}
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(state)
+}
+
+// update applies the levels of a PUT request, if all are valid.
+func (h *Handler) update(r *http.Request) error {
+	var u handlerUpdate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&u); err != nil {
+		return fmt.Errorf("invalid request: %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prev := h.logger.levelState()
+	next, err := prev.apply(u)
+	if err != nil {
+		return err
+	}
+	var revert time.Duration
+	if u.Revert != "" {
+		if revert, err = time.ParseDuration(u.Revert); err != nil || revert <= 0 {
+			return fmt.Errorf("invalid revert %q", u.Revert)
+		}
+	}
+
+	// Revert to the levels before any pending revert, rather than to the
+	// temporary levels
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+		prev = h.saved
+	}
+	h.logger.setLevelState(next)
+	h.revision++
+	if revert > 0 {
+		revision := h.revision
+		h.saved, h.revertAt = prev, time.Now().Add(revert)
+		h.timer = time.AfterFunc(revert, func() { h.revert(revision) })
+	}
+	return nil
+}
+
+// revert restores the levels saved by the PUT of a revision, unless they were
+// changed by another PUT.
+func (h *Handler) revert(revision uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.revision != revision {
+		return
+	}
+	h.timer = nil
+	h.logger.setLevelState(h.saved)
+}
+
+// levelState holds the levels of a Logger.
+type levelState struct {
+	level      syslog.Priority
+	facility   syslog.Priority
+	components map[string]syslog.Priority
+	sinks      []sinkState
+}
+
+type sinkState struct {
+	name  string
+	level syslog.Priority
+}
+
+// levelState returns the current levels of l.
+func (l *Logger) levelState() levelState {
+	l.priorityMu.RLock()
+	s := levelState{
+		level:      l.getLevel(),
+		facility:   l.getFacility(),
+		components: make(map[string]syslog.Priority, len(l.levels)),
+	}
+	for pattern, lvl := range l.levels {
+		s.components[pattern] = lvl
+	}
+	l.priorityMu.RUnlock()
+
+	for _, entry := range l.getSinks() {
+		s.sinks = append(s.sinks, sinkState{name: entry.name, level: entry.level})
+	}
+	return s
+}
+
+// setLevelState changes the levels of l to those of s. Sinks that have been
+// removed since s was taken are ignored.
+func (l *Logger) setLevelState(s levelState) {
+	l.once.Do(l.initPrinter)
+
+	l.priorityMu.Lock()
+	l.setLevel(s.level)
+	l.priority = syslevel(l.priority, s.facility)
+	l.isKernel = s.facility == syslog.LOG_KERN
+	l.levels = make(map[string]syslog.Priority, len(s.components))
+	for pattern, lvl := range s.components {
+		l.levels[pattern] = lvl
+	}
+	l.priorityMu.Unlock()
+
+	for _, sink := range s.sinks {
+		_ = l.SetSinkLevel(sink.name, sink.level)
+	}
+}
+
+// apply returns a copy of s with the levels of u, or an error if any is
+// invalid.
+func (s levelState) apply(u handlerUpdate) (levelState, error) {
+	next := levelState{
+		level:      s.level,
+		facility:   s.facility,
+		components: make(map[string]syslog.Priority, len(s.components)),
+		sinks:      append([]sinkState(nil), s.sinks...),
+	}
+	for pattern, lvl := range s.components {
+		next.components[pattern] = lvl
+	}
+
+	var err error
+	if u.Level != nil {
+		if next.level, err = ParseLevel(*u.Level); err != nil {
+			return s, err
+		}
+	}
+	if u.Facility != nil {
+		if next.facility, err = parseFacility(*u.Facility); err != nil {
+			return s, err
+		}
+	}
+	for pattern, name := range u.Components {
+		if name == nil {
+			delete(next.components, pattern)
+			continue
+		}
+		if pattern == "" || pattern == "*" {
+			return s, fmt.Errorf("invalid component pattern %q", pattern)
+		}
+		lvl, err := ParseLevel(*name)
+		if err != nil {
+			return s, err
+		}
+		next.components[pattern] = lvl
+	}
+Sinks:
+	for _, sink := range u.Sinks {
+		lvl, err := parseSinkLevel(sink.Level)
+		if err != nil {
+			return s, err
+		}
+		if lvl == LevelInherit && sink.Name == SyslogSink {
+			return s, fmt.Errorf("sink %q cannot inherit its level", sink.Name)
+		}
+		for i := range next.sinks {
+			if next.sinks[i].name == sink.Name {
+				next.sinks[i].level = lvl
+				continue Sinks
+			}
+		}
+		return s, fmt.Errorf("sink %q does not exist", sink.Name)
+	}
+	return next, nil
+}
+
+// marshal returns s as rendered by the Handler.
+func (s levelState) marshal() handlerState {
+	state := handlerState{
+		Level:      levelName(s.level),
+		Facility:   facilityName(s.facility),
+		Components: make(map[string]string, len(s.components)),
+		Sinks:      make([]handlerSink, len(s.sinks)),
+	}
+	for pattern, lvl := range s.components {
+		state.Components[pattern] = levelName(lvl)
+	}
+	for i, sink := range s.sinks {
+		state.Sinks[i] = handlerSink{Name: sink.name, Level: "inherit"}
+		if sink.level != LevelInherit {
+			state.Sinks[i].Level = levelName(sink.level)
+		}
+	}
+	return state
+}
+
+// parseSinkLevel parses a level as accepted by ParseLevel or "inherit" for
+// LevelInherit.
+func parseSinkLevel(name string) (syslog.Priority, error) {
+	if strings.EqualFold(name, "inherit") {
+		return LevelInherit, nil
+	}
+	return ParseLevel(name)
+}
+
+// parseFacility parses a syslog facility name, e.g. "local0".
+func parseFacility(name string) (syslog.Priority, error) {
+	for i, fac := range facilityNames {
+		if strings.EqualFold(name, fac) {
+			return syslog.Priority(i << 3), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid facility: %q", name)
+}
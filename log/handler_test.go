@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/syslog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+type handlerState struct {
+	Level      string            `json:"level"`
+	Facility   string            `json:"facility"`
+	Components map[string]string `json:"components"`
+	Sinks      []struct {
+		Name  string `json:"name"`
+		Level string `json:"level"`
+	} `json:"sinks"`
+	RevertAt *time.Time `json:"revert_at"`
+}
+
+func serveHandler(t *testing.T, h http.Handler, method, body string) (int, handlerState) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/log", strings.NewReader(body)))
+	var state handlerState
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+	}
+	return rec.Code, state
+}
+
+func TestHandler(t *testing.T) {
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	logger.SetComponentLevel("proxy", syslog.LOG_WARNING)
+	if err := logger.AddSink("file", log.NewWriterSink(ioutil.Discard, nil), syslog.LOG_DEBUG); err != nil {
+		t.Fatalf("error adding sink: %v", err)
+	}
+	h := log.NewHandler(logger)
+
+	code, state := serveHandler(t, h, http.MethodGet, "")
+	if code != http.StatusOK || state.Level != "info" || state.Facility != "local0" ||
+		state.Components["proxy"] != "warning" || len(state.Sinks) != 2 ||
+		state.Sinks[0].Level != "inherit" || state.Sinks[1].Level != "debug" || state.RevertAt != nil {
+		t.Errorf("unexpected state %d %+v", code, state)
+	}
+
+	// Expect invalid updates to change nothing
+	for _, body := range []string{
+		`{"level": "loud"}`,
+		`{"level": "debug", "sinks": [{"name": "missing", "level": "info"}]}`,
+		`{"facility": "local9"}`,
+		`{"revert": "-1s"}`,
+		`{"unknown": 1}`,
+	} {
+		if code, _ := serveHandler(t, h, http.MethodPut, body); code != http.StatusBadRequest {
+			t.Errorf("expected bad request for %s, got %d", body, code)
+		}
+	}
+	if code, _ := serveHandler(t, h, http.MethodPost, "{}"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed, got %d", code)
+	}
+	if lvl := logger.GetLevel(); lvl != syslog.LOG_INFO {
+		t.Errorf("expected level unchanged, got %v", lvl)
+	}
+
+	code, state = serveHandler(t, h, http.MethodPut,
+		`{"level": "debug", "facility": "local3", "components": {"proxy": null, "proxy.*": "err"},
+		  "sinks": [{"name": "file", "level": "inherit"}]}`)
+	if code != http.StatusOK || state.Level != "debug" || state.Facility != "local3" ||
+		len(state.Components) != 1 || state.Components["proxy.*"] != "err" || state.Sinks[1].Level != "inherit" {
+		t.Errorf("unexpected state %d %+v", code, state)
+	}
+	if lvl := logger.GetComponentLevel("proxy.preface"); lvl != syslog.LOG_ERR {
+		t.Errorf("expected component level %v, got %v", syslog.LOG_ERR, lvl)
+	}
+	if fac := logger.GetFacility(); fac != syslog.LOG_LOCAL3 {
+		t.Errorf("expected facility %v, got %v", syslog.LOG_LOCAL3, fac)
+	}
+	if lvl, _ := logger.GetSinkLevel("file"); lvl != log.LevelInherit {
+		t.Errorf("expected file sink to inherit, got %v", lvl)
+	}
+}
+
+func TestHandlerRevert(t *testing.T) {
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	h := log.NewHandler(logger)
+
+	// Expect a second PUT to keep reverting to the levels before the first
+	if code, _ := serveHandler(t, h, http.MethodPut, `{"level": "debug", "revert": "1h"}`); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	code, state := serveHandler(t, h, http.MethodPut, `{"level": "notice", "revert": "100ms"}`)
+	if code != http.StatusOK || state.Level != "notice" || state.RevertAt == nil {
+		t.Fatalf("unexpected state %d %+v", code, state)
+	}
+	for start := time.Now(); logger.GetLevel() != syslog.LOG_INFO; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("expected level reverted to %v, got %v", syslog.LOG_INFO, logger.GetLevel())
+		}
+	}
+	if _, state := serveHandler(t, h, http.MethodGet, ""); state.RevertAt != nil {
+		t.Errorf("expected no pending revert, got %v", state.RevertAt)
+	}
+
+	// Expect a PUT without revert to cancel a pending revert
+	serveHandler(t, h, http.MethodPut, `{"level": "debug", "revert": "100ms"}`)
+	serveHandler(t, h, http.MethodPut, `{"level": "err"}`)
+	time.Sleep(300 * time.Millisecond)
+	if lvl := logger.GetLevel(); lvl != syslog.LOG_ERR {
+		t.Errorf("expected level %v, got %v", syslog.LOG_ERR, lvl)
+	}
+}
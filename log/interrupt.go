@@ -5,17 +5,34 @@ package log
 
 import (
 	"context"
+	"log/syslog"
 	"os"
 	"os/signal"
 	"syscall"
 )
 
+// VerbosityTarget selects a verbosity level changed by SignalVerbosityChanges.
+type VerbosityTarget int
+
+const (
+	// LocalVerbosity is the level of local output, see SetLevel.
+	LocalVerbosity VerbosityTarget = iota
+	// SyslogVerbosity is the level of syslog, see SetSyslogLevel.
+	SyslogVerbosity
+)
+
 // SignalVerbosityChanges captures SIGUSR1 and SIGUSR2 and decreases and
-// increases verbosity on each signal, respectively.
+// increases verbosity on each signal, respectively. By default the level of
+// local output is changed. If targets are given, the level of each target is
+// changed instead.
 //
 // This function spawns a goroutine in order to make it safe to send a USR1 or
 // USR2 signal as soon as the function has returned.
-func SignalVerbosityChanges(ctx context.Context, l *Logger) {
+func SignalVerbosityChanges(ctx context.Context, l *Logger, targets ...VerbosityTarget) {
+	if len(targets) == 0 {
+		targets = []VerbosityTarget{LocalVerbosity}
+	}
+
 	decC := make(chan os.Signal, 1)
 	incC := make(chan os.Signal, 1)
 	signal.Notify(decC, syscall.SIGUSR1)
@@ -27,14 +44,102 @@ func SignalVerbosityChanges(ctx context.Context, l *Logger) {
 			case <-ctx.Done():
 				return
 			case <-decC:
-				l.priorityMu.Lock()
-				l.setLevel(l.getLevel() - 1)
-				l.priorityMu.Unlock()
+				for _, target := range targets {
+					l.changeVerbosity(target, -1)
+				}
 			case <-incC:
-				l.priorityMu.Lock()
-				l.setLevel(l.getLevel() + 1)
-				l.priorityMu.Unlock()
+				for _, target := range targets {
+					l.changeVerbosity(target, 1)
+				}
 			}
 		}
 	}()
 }
+
+// SignalReloads captures SIGHUP and reloads l on each signal, see
+// (*Logger).Reload. Errors are logged by l. If WatchConfig is also used with
+// l, then each signal re-applies the config and then reloads l once.
+//
+// This function spawns a goroutine in order to make it safe to send a HUP
+// signal as soon as the function has returned.
+func SignalReloads(ctx context.Context, l *Logger) {
+	l.watchHUP(ctx, nil)
+}
+
+// hupHook re-applies a config on SIGHUP.
+type hupHook struct {
+	apply func() error
+}
+
+// watchHUP handles SIGHUP for l until ctx is done, re-applying the config of
+// hook, if not nil, and then reloading l. A single goroutine handles the
+// signal for l while the context of any call is not done, so that each
+// signal is handled once.
+func (l *Logger) watchHUP(ctx context.Context, hook *hupHook) {
+	l.hupMu.Lock()
+	if l.hupWatches == 0 {
+		hupC := make(chan os.Signal, 1)
+		signal.Notify(hupC, syscall.SIGHUP)
+		l.hupQuit = make(chan struct{})
+		go l.handleHUP(hupC, l.hupQuit)
+	}
+	l.hupWatches++
+	if hook != nil {
+		l.hupConfig = hook
+	}
+	l.hupMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.hupMu.Lock()
+		defer l.hupMu.Unlock()
+		if hook != nil && l.hupConfig == hook {
+			l.hupConfig = nil
+		}
+		if l.hupWatches--; l.hupWatches == 0 {
+			close(l.hupQuit)
+		}
+	}()
+}
+
+// handleHUP re-applies the config and reloads l on each signal received by
+// hupC until quit is closed.
+func (l *Logger) handleHUP(hupC chan os.Signal, quit chan struct{}) {
+	defer signal.Stop(hupC)
+	for {
+		select {
+		case <-quit:
+			return
+		case <-hupC:
+		}
+
+		l.hupMu.Lock()
+		hook := l.hupConfig
+		l.hupMu.Unlock()
+		if hook != nil {
+			if err := hook.apply(); err != nil {
+				l.Err(err)
+			}
+		}
+		if err := l.Reload(); err != nil {
+			l.Err(err)
+		}
+	}
+}
+
+// changeVerbosity changes the level of target by delta.
+func (l *Logger) changeVerbosity(target VerbosityTarget, delta syslog.Priority) {
+	switch target {
+	case SyslogVerbosity:
+		l.sinksOnce.Do(l.initSinks)
+		l.sinksMu.Lock()
+		if lvl := l.getSyslogLevel() + delta; lvl <= syslog.LOG_DEBUG {
+			l.setSyslogLevel(lvl)
+		}
+		l.sinksMu.Unlock()
+	default:
+		l.priorityMu.Lock()
+		l.setLevel(l.getLevel() + delta)
+		l.priorityMu.Unlock()
+	}
+}
@@ -59,3 +59,66 @@ WaitForIncrease:
 		}
 	}
 }
+
+func TestSignalSyslogVerbosityChanges(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		pid         = os.Getpid()
+		timeout     = time.After(time.Second)
+		logger      = new(log.Logger)
+	)
+	defer cancel()
+	logger.SetLevel(syslog.LOG_INFO)
+	logger.SetSyslogLevel(syslog.LOG_WARNING)
+	log.SignalVerbosityChanges(ctx, logger, log.SyslogVerbosity)
+
+	// Increase dynamically, leaving the local level unchanged
+	if err := syscall.Kill(pid, syscall.SIGUSR2); err != nil {
+		t.Fatalf("got error sending USR2 signal to self: %v", err)
+	}
+WaitForIncrease:
+	for {
+		if lvl := logger.GetSyslogLevel(); lvl == syslog.LOG_NOTICE {
+			break WaitForIncrease
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("timed out before signal increased syslog verbosity")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if lvl := logger.GetLevel(); lvl != syslog.LOG_INFO {
+		t.Errorf("expected local level %d, got %d", syslog.LOG_INFO, lvl)
+	}
+}
+
+// reloadSink counts reloads.
+type reloadSink struct{ reloads chan struct{} }
+
+func (reloadSink) WriteRecord(*log.Record) error { return nil }
+
+func (s reloadSink) Reload() error {
+	s.reloads <- struct{}{}
+	return nil
+}
+
+func TestSignalReloads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := new(log.Logger)
+	sink := reloadSink{reloads: make(chan struct{}, 1)}
+	if err := logger.AddSink("reload", sink, log.LevelInherit); err != nil {
+		t.Fatalf("error adding sink: %v", err)
+	}
+	log.SignalReloads(ctx, logger)
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("got error sending HUP signal to self: %v", err)
+	}
+	select {
+	case <-sink.reloads:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out before signal reloaded sink")
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonKeys are the fixed properties of each JSON log.
+var jsonKeys = map[string]bool{
+	"time": true, "level": true, "facility": true, "service": true, "pid": true, "message": true,
+}
+
+// JSONEncoder renders records as single line JSON objects with the
+// properties time, level, facility, service, pid and message, followed by a
+// property for each field. Field values keep their JSON type where possible
+// and errors and fmt.Stringers are written as strings. Fields whose keys
+// collide with one of the fixed properties are prefixed with "fields.".
+type JSONEncoder struct{}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(r *Record) ([]byte, error) {
+	buf := make([]byte, 0, 128+len(r.Message))
+	buf = append(buf, `{"time":`...)
+	buf = appendJSONString(buf, r.Time.UTC().Format(time.RFC3339Nano))
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, levelName(r.Priority))
+	buf = append(buf, `,"facility":`...)
+	buf = appendJSONString(buf, facilityName(r.Priority))
+	buf = append(buf, `,"service":`...)
+	buf = appendJSONString(buf, r.Service)
+	buf = append(buf, `,"pid":`...)
+	buf = strconv.AppendInt(buf, int64(r.PID), 10)
+	buf = append(buf, `,"message":`...)
+	buf = appendJSONString(buf, r.Message)
+
+	for _, f := range r.Fields {
+		buf = append(buf, ',')
+		if jsonKeys[f.Key] {
+			buf = appendJSONString(buf, "fields."+f.Key)
+		} else {
+			buf = appendJSONString(buf, f.Key)
+		}
+		buf = append(buf, ':')
+		buf = f.appendJSON(buf)
+	}
+	return append(buf, "}\n"...), nil
+}
+
+// appendJSONValue appends v to buf, keeping its JSON type where possible.
+func appendJSONValue(buf []byte, v interface{}) []byte { //nolint: gocyclo
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "null"...)
+	case string:
+		return appendJSONString(buf, v)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float32:
+		return appendJSONFloat(buf, float64(v), 32)
+	case float64:
+		return appendJSONFloat(buf, v, 64)
+	case error:
+		return appendJSONString(buf, callString(v, "Error", v.Error))
+	case json.Marshaler:
+		return appendJSONMarshal(buf, v)
+	case fmt.Stringer:
+		return appendJSONString(buf, callString(v, "String", v.String))
+	default:
+		return appendJSONMarshal(buf, v)
+	}
+}
+
+// appendJSONFloat appends f as a JSON number or, if it is NaN or infinite,
+// as a string.
+func appendJSONFloat(buf []byte, f float64, bitSize int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bitSize))
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, bitSize)
+}
+
+// appendJSONMarshal appends v encoded by encoding/json or, if that fails, its
+// fmt representation as a string.
+func appendJSONMarshal(buf []byte, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+// appendJSONString appends s as a quoted and escaped JSON string.
+func appendJSONString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				buf = append(buf, "\ufffd"...)
+			} else {
+				buf = append(buf, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, '\\', 'n')
+		case c == '\r':
+			buf = append(buf, '\\', 'r')
+		case c == '\t':
+			buf = append(buf, '\\', 't')
+		case c < 0x20:
+			buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+		i++
+	}
+	return append(buf, '"')
+}
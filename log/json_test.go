@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/syslog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerJSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetOutputFormat(log.JSONOutput)
+	logger.SetFacility(syslog.LOG_LOCAL3)
+
+	logger.WithFields(map[string]interface{}{
+		"count":   3,
+		"ratio":   0.5,
+		"ok":      true,
+		"err":     errors.New("failed"),
+		"timeout": time.Second,
+		"none":    nil,
+		"message": "collides",
+	}).Warningf("hello %q\n", "world")
+
+	if !bytes.HasSuffix(buf.Bytes(), []byte("}\n")) || bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Fatalf("expected a single JSON line, got %q", buf.String())
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatalf("error decoding %q: %v", buf.String(), err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, actual["time"].(string)); err != nil {
+		t.Errorf("expected RFC3339 time, got %v", actual["time"])
+	}
+	expect := map[string]interface{}{
+		"level":          "warning",
+		"facility":       "local3",
+		"pid":            float64(os.Getpid()),
+		"message":        `hello "world"`,
+		"count":          float64(3),
+		"ratio":          0.5,
+		"ok":             true,
+		"err":            "failed",
+		"timeout":        "1s",
+		"none":           nil,
+		"fields.message": "collides",
+	}
+	for key, value := range expect {
+		if actual[key] != value {
+			t.Errorf("expected %q to be %#v, got %#v", key, value, actual[key])
+		}
+	}
+}
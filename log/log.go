@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -44,6 +45,37 @@ func init() {
 // used. If no non-remote logging is desired, set output to ioutil.Discard.
 func SetOutput(w io.Writer) { DefaultLogger.SetOutput(w) }
 
+// SetEncoder changes the layout of local logs. If e is nil then a TextEncoder
+// will be used.
+func SetEncoder(e Encoder) { DefaultLogger.SetEncoder(e) }
+
+// SetOutputFormat changes the layout of local logs to one of the built-in
+// encoders. The default is TextOutput.
+func SetOutputFormat(f OutputFormat) { DefaultLogger.SetOutputFormat(f) }
+
+// SetSampling limits the rate of logs with the same level, component and
+// message template. See (*Logger).SetSampling.
+func SetSampling(s Sampling) { DefaultLogger.SetSampling(s) }
+
+// AddSink adds a named sink that logs are written to at and below lvl. See
+// (*Logger).AddSink.
+func AddSink(name string, s Sink, lvl syslog.Priority) error {
+	return DefaultLogger.AddSink(name, s, lvl)
+}
+
+// RemoveSink removes a named sink, closing it if it implements io.Closer.
+func RemoveSink(name string) error { return DefaultLogger.RemoveSink(name) }
+
+// CaptureStdLog redirects the output of the standard library's global logger
+// to the default logger. See (*Logger).CaptureStdLog.
+func CaptureStdLog(lvl syslog.Priority) (restore func()) { return DefaultLogger.CaptureStdLog(lvl) }
+
+// SetSinkDedup collapses identical consecutive logs written to a named sink.
+// See (*Logger).SetSinkDedup.
+func SetSinkDedup(name string, flush time.Duration) error {
+	return DefaultLogger.SetSinkDedup(name, flush)
+}
+
 // SetFacility alters the syslog facility used for logs. If the priority
 // includes a verbosity level it will be ignored.
 func SetFacility(p syslog.Priority) { DefaultLogger.SetFacility(p) }
@@ -80,6 +112,33 @@ func ParseLevel(prio string) (syslog.Priority, error) {
 	}
 }
 
+// levelNames are the names of syslog severities as accepted by ParseLevel,
+// indexed by severity.
+var levelNames = [...]string{
+	syslog.LOG_EMERG:   "emerg",
+	syslog.LOG_ALERT:   "alert",
+	syslog.LOG_CRIT:    "crit",
+	syslog.LOG_ERR:     "err",
+	syslog.LOG_WARNING: "warning",
+	syslog.LOG_NOTICE:  "notice",
+	syslog.LOG_INFO:    "info",
+	syslog.LOG_DEBUG:   "debug",
+}
+
+// facilityNames are the names of syslog facilities, indexed by facility
+// number (the facility portion of the priority shifted right by 3).
+var facilityNames = [...]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Helper func returning the name of the severity portion of a priority.
+func levelName(p syslog.Priority) string { return levelNames[p&severityMask] }
+
+// Helper func returning the name of the facility portion of a priority.
+func facilityName(p syslog.Priority) string { return facilityNames[(p&facilityMask)>>3] }
+
 // SetLevel alters the verbosity level that log will print at and below. It
 // takes values syslog.LOG_EMERG...syslog.LOG_DEBUG. If the priority includes a
 // facility it will be ignored.
@@ -89,9 +148,33 @@ func SetLevel(p syslog.Priority) { DefaultLogger.SetLevel(p) }
 // can be compared to syslog.LOG_EMERG...syslog.LOG_DEBUG.
 func GetLevel() syslog.Priority { return DefaultLogger.GetLevel() }
 
+// Component returns a Printer for a named component. See
+// (*Logger).Component.
+func Component(name string) Printer { return DefaultLogger.Component(name) }
+
+// SetComponentLevel sets the verbosity level of a component and, unless they
+// have their own level, its children. See (*Logger).SetComponentLevel.
+func SetComponentLevel(pattern string, p syslog.Priority) {
+	DefaultLogger.SetComponentLevel(pattern, p)
+}
+
+// SetLevelSpec replaces the levels of all components with the levels of a
+// comma separated list of pattern=level pairs, e.g. "*=info,proxy.*=debug".
+func SetLevelSpec(spec string) error { return DefaultLogger.SetLevelSpec(spec) }
+
 // ConnectSyslog connects to a remote syslog. If addr is an empty string, it
 // will connect to the local syslog service.
-func ConnectSyslog(addr string) error { return DefaultLogger.ConnectSyslog(addr) }
+func ConnectSyslog(addr string, opts ...SyslogOption) error {
+	return DefaultLogger.ConnectSyslog(addr, opts...)
+}
+
+// SetSyslogLevel alters the verbosity level that logs will be sent to syslog
+// at and below, independent of the level of local output.
+func SetSyslogLevel(p syslog.Priority) { DefaultLogger.SetSyslogLevel(p) }
+
+// GetSyslogLevel returns the verbosity level that logs will be sent to syslog
+// at and below.
+func GetSyslogLevel() syslog.Priority { return DefaultLogger.GetSyslogLevel() }
 
 // DisconnectSyslog closes the connection to syslog.
 func DisconnectSyslog() error { return DefaultLogger.DisconnectSyslog() }
@@ -4,17 +4,9 @@
 package log
 
 import (
-	"crypto/tls"
-	"fmt"
 	"io"
-	"log"
 	"log/syslog"
-	"os"
-	"strings"
 	"sync"
-	"time"
-
-	slog "github.com/open-ness/common/log/syslog"
 )
 
 // Logger implements syslog logging funcs and can be connected to a syslog
@@ -24,16 +16,33 @@ type Logger struct {
 	Printer
 	once sync.Once
 
-	outMu sync.RWMutex
-	out   io.Writer
+	sinksOnce      sync.Once
+	sinksMu        sync.RWMutex
+	sinks          []sinkEntry
+	syslogLevel    syslog.Priority
+	syslogLevelSet bool // syslogLevel was explicitly set
 
 	priorityMu sync.RWMutex
 	priority   syslog.Priority
-	disabled   bool // level was explicitly set to EMERG
-	isKernel   bool // facility was explicitly set to KERN
+	disabled   bool                       // level was explicitly set to EMERG
+	isKernel   bool                       // facility was explicitly set to KERN
+	levels     map[string]syslog.Priority // levels of components by pattern
+
+	samplingMu sync.RWMutex
+	sampler    *sampler // nil unless sampling is set
+
+	callerMu   sync.RWMutex
+	caller     bool            // tag logs with their caller
+	stackLevel syslog.Priority // tag logs at and above with their stack
+	stackSet   bool            // stackLevel was explicitly set
 
-	syslogMu sync.RWMutex
-	syslogW  *slog.Writer
+	configMu sync.Mutex
+	config   *Config // last applied by ApplyConfig
+
+	hupMu      sync.Mutex
+	hupWatches int           // contexts of SignalReloads and WatchConfig not done
+	hupQuit    chan struct{} // closed when hupWatches drops to zero
+	hupConfig  *hupHook      // re-applies the config of WatchConfig, if any
 }
 
 // Must be called before any changing any writers or priority in order to
@@ -43,12 +52,44 @@ func (l *Logger) initPrinter() { l.Printer = l.WithFields(nil) }
 // SetOutput changes the writer of local logs written by each logging func in
 // addition to any remote syslog connection. If w is nil then os.Stderr will be
 // used. If no non-remote logging is desired, set output to ioutil.Discard.
+//
+// The output is written to by the output sink, which is re-added if it was
+// removed.
 func (l *Logger) SetOutput(w io.Writer) {
 	l.once.Do(l.initPrinter)
+	l.outputSink().setWriter(w)
+}
+
+// SetEncoder changes the layout of local logs. If e is nil then a TextEncoder
+// will be used.
+func (l *Logger) SetEncoder(e Encoder) {
+	l.once.Do(l.initPrinter)
+	l.outputSink().setEncoder(e)
+}
+
+// OutputFormat selects one of the built-in layouts of local logs.
+type OutputFormat int
+
+const (
+	// TextOutput writes logs with a TextEncoder.
+	TextOutput OutputFormat = iota
+	// JSONOutput writes logs with a JSONEncoder.
+	JSONOutput
+	// LogfmtOutput writes logs with a LogfmtEncoder.
+	LogfmtOutput
+)
 
-	l.outMu.Lock()
-	l.out = w
-	l.outMu.Unlock()
+// SetOutputFormat changes the layout of local logs to one of the built-in
+// encoders. The default is TextOutput.
+func (l *Logger) SetOutputFormat(f OutputFormat) {
+	switch f {
+	case JSONOutput:
+		l.SetEncoder(JSONEncoder{})
+	case LogfmtOutput:
+		l.SetEncoder(LogfmtEncoder{})
+	default:
+		l.SetEncoder(TextEncoder{})
+	}
 }
 
 // SetFacility alters the syslog facility used for logs. If the priority
@@ -121,164 +162,31 @@ func (l *Logger) getLevel() syslog.Priority {
 	return lvl
 }
 
-// ConnectSyslog connects to a remote syslog. If addr is an empty string, it
-// will connect to the local syslog service.
-func (l *Logger) ConnectSyslog(addr string) error {
-	net := "udp"
-	if addr == "" {
-		net = ""
-	}
-	return l.connect(net, addr, nil, slog.DialTLS)
+// writer returns a func writing messages of a component, set by the level
+// patterns of the component, with fields to all sinks but syslog.
+func (l *Logger) writer(patterns []string, fields []Field) func(syslog.Priority, string) {
+	return (&printerOutput{l: l, patterns: patterns, fields: fields}).write
 }
 
-// ConnectSyslogTLS connects to a remote syslog, performing a TLS client
-// handshake. This is always done over TCP and the addr cannot be empty (in an
-// attempt to connect to the local syslog service).
-func (l *Logger) ConnectSyslogTLS(addr string, conf *tls.Config) error {
-	return l.connect("tcp", addr, conf, slog.DialTLS)
+// syslogWriter returns a func writing messages with fields to the syslog sink.
+func (l *Logger) syslogWriter(fields []Field) func(syslog.Priority, string) {
+	return (&printerOutput{l: l, fields: fields}).writeSyslog
 }
 
-func (l *Logger) connect(net, addr string, conf *tls.Config,
-	dial func(string, string, syslog.Priority, string, *tls.Config) (*slog.Writer, error)) error {
-	l.once.Do(l.initPrinter)
-
-	l.syslogMu.Lock()
-	defer l.syslogMu.Unlock()
-
-	if l.syslogW != nil {
-		// TODO(ben): handle replacing a syslog connection
-		return fmt.Errorf("syslog already dialed")
-	}
-
-	// Get syslog facility and combine with INFO level default logging.
-	// DEBUG will be used for syslogW.Write, which won't be called.
-	l.priorityMu.RLock()
-	priority := syslevel(syslog.LOG_DEBUG, l.getFacility())
-	l.priorityMu.RUnlock()
-
-	// Dial syslog
-	var err error
-	l.syslogW, err = dial(net, addr, priority, svcName, conf)
-	return err
+// printerOutput writes the messages of a Printer with its fields. Its methods
+// are the default Write and WriteSyslog of a Printer.
+type printerOutput struct {
+	l        *Logger
+	patterns []string
+	fields   []Field
 }
 
-// DisconnectSyslog closes the connection to syslog.
-func (l *Logger) DisconnectSyslog() error {
-	l.syslogMu.Lock()
-	defer l.syslogMu.Unlock()
-
-	if l.syslogW == nil {
-		return nil
-	}
-	err := l.syslogW.Close()
-	l.syslogW = nil
-	return err
+func (o *printerOutput) write(p syslog.Priority, msg string) {
+	o.l.log(p, o.patterns, o.fields, msg, localSinks)
 }
 
-func (l *Logger) format(fields map[string]interface{}) func(string, ...interface{}) string {
-	var tags []string
-	for key, value := range fields {
-		field := "[" + key + "]"
-		if value != nil {
-			field = fmt.Sprintf("[%s=%v]", key, value)
-		}
-		tags = append(tags, field)
-	}
-	data := strings.Join(tags, " ")
-	if len(data) > 0 {
-		data += " "
-	}
-
-	return func(frmt string, a ...interface{}) string {
-		if frmt == "" {
-			return data + fmt.Sprint(a...)
-		}
-		return data + fmt.Sprintf(frmt, a...)
-	}
-}
-
-func (l *Logger) write(p syslog.Priority, msg string) {
-	// Bail if level is too low
-	if (p & severityMask) > l.GetLevel() {
-		return
-	}
-
-	// Write to local
-	l.outMu.RLock()
-	out := l.out
-	l.outMu.RUnlock()
-	if out == nil {
-		out = os.Stderr
-	}
-	// ensure msg ends in a \n
-	nl := ""
-	if !strings.HasSuffix(msg, "\n") {
-		nl = "\n"
-	}
-	_, err := fmt.Fprintf(out, "<%d>%s %s[%d]: %s%s",
-		syslevel(p, l.priority), time.Now().Format(time.Stamp), svcName, os.Getpid(), msg, nl)
-	if err != nil {
-		log.Printf("error writing to local log: %s", err)
-	}
-}
-
-func (l *Logger) writeSyslog(p syslog.Priority, msg string) { //nolint: gocyclo
-	// ensure msg ends in a \n
-	if !strings.HasSuffix(msg, "\n") {
-		msg += "\n"
-	}
-
-	l.syslogMu.RLock()
-	syslogW := l.syslogW
-	l.syslogMu.RUnlock()
-	if syslogW == nil {
-		return
-	}
-
-	var err error
-	switch p & severityMask {
-	case syslog.LOG_DEBUG:
-		err = syslogW.Debug(msg)
-	case syslog.LOG_INFO:
-		err = syslogW.Info(msg)
-	case syslog.LOG_NOTICE:
-		err = syslogW.Notice(msg)
-	case syslog.LOG_WARNING:
-		err = syslogW.Warning(msg)
-	case syslog.LOG_ERR:
-		err = syslogW.Err(msg)
-	case syslog.LOG_CRIT:
-		err = syslogW.Crit(msg)
-	case syslog.LOG_ALERT:
-		err = syslogW.Alert(msg)
-	case syslog.LOG_EMERG:
-		err = syslogW.Emerg(msg)
-	default:
-		panic("unknown log level")
-	}
-	if err != nil {
-		// TODO(ben): handle disconnect
-		// if netErr, ok := err.(*net.OpError); ok && netErr.Op == "dial" {
-		//
-		// }
-
-		// Get backup output to write to
-		l.outMu.RLock()
-		out := l.out
-		l.outMu.RUnlock()
-		if out == nil {
-			out = os.Stderr
-		}
-
-		// Write error to backup writer
-		errmsg := strings.TrimSuffix("error writing to syslog: "+err.Error(), "\n") + "\n"
-		_, err2 := fmt.Fprintf(out, "<%d>%s %s[%d]: %s",
-			syslevel(p, l.priority), time.Now().Format(time.RFC3339Nano), svcName, os.Getpid(), errmsg)
-		if err2 != nil {
-			log.Printf("error writing to local log about being unable to write to syslog:\n%s\n\n%s",
-				err, err2)
-		}
-	}
+func (o *printerOutput) writeSyslog(p syslog.Priority, msg string) {
+	o.l.log(p, nil, o.fields, msg, syslogSinks)
 }
 
 // Helper func to combine a level with a facility into a syslog priority.
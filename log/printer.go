@@ -10,28 +10,90 @@ import (
 
 // Printer formats and writes logs conditionally based on the current priority
 // level.
+//
+// Printers returned by a Logger leave Format, Write and WriteSyslog nil and
+// write structured records to its sinks. If any of them is set, then the
+// message is formatted and written by those funcs instead, as plain text, with
+// the defaults used for the others. For example, setting Write to a func
+// calling the Print method of another Printer forwards messages to it.
 type Printer struct {
 	Format      func(frmt string, a ...interface{}) string
 	Write       func(lvl syslog.Priority, msg string)
 	WriteSyslog func(lvl syslog.Priority, msg string)
+
+	logger    *Logger
+	fields    []Field
+	component string
+	patterns  []string // level patterns of component
 }
 
 // WithField returns a Printer tagged with a single field.
 func (l *Logger) WithField(key string, value interface{}) Printer {
-	return l.WithFields(map[string]interface{}{key: value})
+	return l.With(Any(key, value))
 }
 
-// WithFields returns a Printer tagged with multiple fields.
+// WithFields returns a Printer tagged with multiple fields, ordered by key.
 func (l *Logger) WithFields(kvs map[string]interface{}) Printer {
+	return l.With(mapFields(kvs)...)
+}
+
+// With returns a Printer tagged with typed fields, which are rendered in the
+// given order.
+func (l *Logger) With(fields ...Field) Printer { return l.printer("", fields) }
+
+func (l *Logger) printer(component string, fields []Field) Printer {
+	patterns := levelPatterns(component)
 	return Printer{
-		Format:      l.format(kvs),
-		Write:       l.write,
-		WriteSyslog: l.writeSyslog,
+		logger:    l,
+		fields:    fields,
+		component: component,
+		patterns:  patterns,
 	}
 }
 
+// WithField returns a child Printer tagged with the fields of p and a single
+// field. If p already has a field with the same key, the value of the child
+// is used.
+func (p Printer) WithField(key string, value interface{}) Printer {
+	return p.With(Any(key, value))
+}
+
+// WithFields returns a child Printer tagged with the fields of p and multiple
+// fields, ordered by key. If p already has fields with the same keys, the
+// values of the child are used.
+func (p Printer) WithFields(kvs map[string]interface{}) Printer {
+	return p.With(mapFields(kvs)...)
+}
+
+// With returns a child Printer tagged with the fields of p followed by typed
+// fields. A field with the same key as a field of p replaces its value
+// without changing its position. The child writes to the same Logger as p,
+// or to DefaultLogger if p was not created by a Logger.
+func (p Printer) With(fields ...Field) Printer {
+	l := p.logger
+	if l == nil {
+		l = DefaultLogger
+	}
+	return l.printer(p.component, mergeFields(p.fields, fields))
+}
+
 // Printf writes message with severity and set facility to output and syslog if connected.
 func (p Printer) Printf(lvl syslog.Priority, frmt string, a ...interface{}) {
+	p.printfDepth(0, lvl, frmt, a...)
+}
+
+// printfDepth is Printf with the caller depth frames above the caller of the
+// funcs of this package, see callerFields.
+func (p Printer) printfDepth(depth int, lvl syslog.Priority, frmt string, a ...interface{}) {
+	// skip formatting if the log would not be written anywhere
+	if p.logger != nil && !p.logger.enabled(lvl, p.patterns) {
+		return
+	}
+	// skip formatting if the log is dropped by sampling
+	if p.logger != nil && !p.logger.sample(lvl, p.component, frmt, a) {
+		return
+	}
+
 	// get formatter and writer with defaults
 	formatter := p.Format
 	if formatter == nil {
@@ -41,13 +103,26 @@ func (p Printer) Printf(lvl syslog.Priority, frmt string, a ...interface{}) {
 			formatter = fmt.Sprintf
 		}
 	}
+	if p.logger != nil && p.Format == nil && p.Write == nil && p.WriteSyslog == nil {
+		fields := p.fields
+		if extra := p.logger.callerFields(lvl, depth); extra != nil {
+			fields = append(fields[:len(fields):len(fields)], extra...)
+		}
+		// write formatted string to all sinks at once
+		p.logger.log(lvl, p.patterns, fields, formatter(frmt, a...), allSinks)
+		return
+	}
+	l := p.logger
+	if l == nil {
+		l = &Logger{priority: lvl}
+	}
 	write := p.Write
 	if write == nil {
-		write = (&Logger{priority: lvl}).write
+		write = l.writer(p.patterns, p.fields)
 	}
 	writeSyslog := p.WriteSyslog
 	if writeSyslog == nil {
-		writeSyslog = (&Logger{}).writeSyslog
+		writeSyslog = l.syslogWriter(p.fields)
 	}
 
 	// write formatted string
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"log/syslog"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/open-ness/common/log"
@@ -46,3 +47,80 @@ func TestPrinterPrint(t *testing.T) {
 		}
 	}
 }
+
+func TestPrinterWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+
+	parent := logger.WithField("component", "api")
+	child := parent.WithFields(map[string]interface{}{"request_id": 7, "component": "api/v2"})
+	grandchild := child.With(log.String("user", "bob"))
+
+	// Parent must be unaffected by children
+	parent.Info("parent")
+	child.Info("child")
+	grandchild.Info("grandchild")
+	expect := []string{
+		"[component=api] parent\n",
+		"[component=api/v2] [request_id=7] child\n",
+		"[component=api/v2] [request_id=7] [user=bob] grandchild\n",
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, line := range expect {
+		if !strings.HasSuffix(lines[i], line) {
+			t.Errorf("expected %q to end with %q", lines[i], line)
+		}
+	}
+
+	// Child must follow the level of the logger
+	buf.Reset()
+	logger.SetLevel(syslog.LOG_WARNING)
+	grandchild.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("expected no output below logger level, got %q", buf.String())
+	}
+}
+
+func TestPrinterOverrides(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+
+	// Expect replaced funcs of a Printer returned by a Logger to be used
+	var written []string
+	p := logger.WithField("k", "v")
+	p.Write = func(lvl syslog.Priority, msg string) { written = append(written, msg) }
+	p.Info("to write")
+	if len(written) != 1 || written[0] != "to write" || buf.Len() != 0 {
+		t.Errorf("expected message written by Write only, got %q and output %q", written, buf.String())
+	}
+
+	p = logger.WithField("k", "v")
+	p.Format = func(frmt string, a ...interface{}) string { return "formatted" }
+	p.Info("msg")
+	if !strings.HasSuffix(buf.String(), "[k=v] formatted\n") {
+		t.Errorf("expected message formatted by Format in output %q", buf.String())
+	}
+
+	// Expect messages forwarded to a Printer of another Logger to be written
+	// by it only
+	var other bytes.Buffer
+	otherLogger := new(log.Logger)
+	otherLogger.SetOutput(&other)
+	buf.Reset()
+	forward := otherLogger.WithField("x", "y")
+	p = logger.WithField("k", "v")
+	p.Write = func(lvl syslog.Priority, msg string) { forward.Print(lvl, msg) }
+	p.Info("forwarded")
+	if buf.Len() != 0 || !strings.HasSuffix(other.String(), "[x=y] forwarded\n") {
+		t.Errorf("expected message in other output only, got %q and %q", buf.String(), other.String())
+	}
+
+	// Expect default funcs to still write records with fields
+	buf.Reset()
+	logger.WithField("k", "v").Info("msg")
+	if !strings.HasSuffix(buf.String(), "[k=v] msg\n") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sampling limits the rate of logs with the same level, component and
+// message template, i.e. the format string of Printf-like funcs or the first
+// argument of Print-like funcs if it is a string and the types of their
+// arguments otherwise.
+// In each interval, the first logs of a template are written and thereafter
+// only every Thereafter-th log, or none if Thereafter is zero. Logs with a
+// template are dropped before they are formatted.
+//
+// At the end of each interval in which logs were dropped, a NOTICE log is
+// written with the number dropped.
+type Sampling struct {
+	// Interval is the period the counts of logs are reset at. Sampling is
+	// disabled if it is zero.
+	Interval time.Duration
+	// First is the number of logs of each template written per interval. If
+	// it is zero, then 1 is used, so that every template is logged.
+	First int
+	// Thereafter is the period of logs of each template written after the
+	// first, e.g. 100 to write every 100th log.
+	Thereafter int
+}
+
+// SuppressedKey is the key of the field holding the number of logs dropped in
+// the summary written by sampling.
+const SuppressedKey = "suppressed"
+
+// SetSampling limits the rate of logs as configured by s, replacing any
+// previous sampling. A zero Sampling disables it.
+func (l *Logger) SetSampling(s Sampling) {
+	l.once.Do(l.initPrinter)
+
+	var next *sampler
+	if s.Interval > 0 {
+		if s.First <= 0 {
+			s.First = 1
+		}
+		next = &sampler{
+			cfg:    s,
+			logger: l,
+			counts: make(map[sampleKey]int),
+			quit:   make(chan struct{}),
+		}
+	}
+
+	l.samplingMu.Lock()
+	prev := l.sampler
+	l.sampler = next
+	l.samplingMu.Unlock()
+	if prev != nil {
+		close(prev.quit)
+	}
+}
+
+// GetSampling returns the sampling set by SetSampling.
+func (l *Logger) GetSampling() Sampling {
+	l.samplingMu.RLock()
+	defer l.samplingMu.RUnlock()
+	if l.sampler == nil {
+		return Sampling{}
+	}
+	return l.sampler.cfg
+}
+
+// sample reports whether a log of a component with severity p, format string
+// frmt and arguments a should be written.
+func (l *Logger) sample(p syslog.Priority, component, frmt string, a []interface{}) bool {
+	l.samplingMu.RLock()
+	s := l.sampler
+	l.samplingMu.RUnlock()
+	if s == nil {
+		return true
+	}
+
+	key := sampleKey{level: p & severityMask, component: component, template: frmt}
+	if frmt == "" && len(a) > 0 {
+		if t, ok := a[0].(string); ok {
+			key.template = t
+		} else {
+			key.template = argTypes(a)
+		}
+	}
+	return s.allow(key)
+}
+
+// argTypes returns the types of arguments, so that logs can be sampled by
+// them without formatting their values.
+func argTypes(a []interface{}) string {
+	types := make([]string, len(a))
+	for i, v := range a {
+		types[i] = fmt.Sprintf("%T", v)
+	}
+	return strings.Join(types, " ")
+}
+
+type sampleKey struct {
+	level     syslog.Priority
+	component string
+	template  string
+}
+
+// sampler counts logs by key in intervals ended by summarize.
+type sampler struct {
+	cfg    Sampling
+	logger *Logger
+	quit   chan struct{} // closed when replaced
+
+	mu         sync.Mutex
+	counts     map[sampleKey]int // in the current interval
+	suppressed int               // in the current interval
+	running    bool              // summarize is running
+}
+
+// allow counts a log and reports whether it should be written.
+func (s *sampler) allow(key sampleKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		// Start an interval
+		s.running = true
+		go s.summarize()
+	}
+
+	n := s.counts[key] + 1
+	s.counts[key] = n
+	if n <= s.cfg.First || (s.cfg.Thereafter > 0 && (n-s.cfg.First)%s.cfg.Thereafter == 0) {
+		return true
+	}
+	s.suppressed++
+	return false
+}
+
+// reset starts a new interval, returning the number of logs suppressed in
+// the previous one. Only call with a lock on the mutex.
+func (s *sampler) reset() int {
+	suppressed := s.suppressed
+	s.counts = make(map[sampleKey]int)
+	s.suppressed = 0
+	return suppressed
+}
+
+// summarize writes the number of logs suppressed in each interval to the
+// Logger, until the sampler is replaced or an interval passes without logs,
+// so that it does not outlive the use of the Logger.
+func (s *sampler) summarize() {
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		idle := len(s.counts) == 0
+		suppressed := s.reset()
+		if idle {
+			s.running = false
+		}
+		s.mu.Unlock()
+		if suppressed > 0 {
+			s.logger.log(syslog.LOG_NOTICE, nil, []Field{Int(SuppressedKey, suppressed)},
+				fmt.Sprintf("suppressed %d logs in the last %s", suppressed, s.cfg.Interval), allSinks)
+		}
+		if idle {
+			return
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoggerSampling(t *testing.T) {
+	var buf syncBuffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetSampling(log.Sampling{Interval: 200 * time.Millisecond, First: 2, Thereafter: 3})
+	defer logger.SetSampling(log.Sampling{})
+
+	// Expect the 1st, 2nd, 5th and 8th log of each template to be written
+	for i := 1; i <= 10; i++ {
+		logger.Errf("flap %d", i)
+		logger.Info("link down")
+	}
+	for _, expect := range []string{"flap 1\n", "flap 2\n", "flap 5\n", "flap 8\n"} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("expected %q in output %q", expect, buf.String())
+		}
+	}
+	if n := strings.Count(buf.String(), "flap"); n != 4 {
+		t.Errorf("expected 4 flap logs, got %d", n)
+	}
+	if n := strings.Count(buf.String(), "link down"); n != 4 {
+		t.Errorf("expected 4 link down logs, got %d", n)
+	}
+
+	// Expect a summary of the suppressed logs at the end of the interval
+	for start := time.Now(); !strings.Contains(buf.String(), "suppressed 12 logs"); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("expected summary of suppressed logs in output %q", buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "[suppressed=12]") {
+		t.Errorf("expected suppressed field in output %q", buf.String())
+	}
+	if s := logger.GetSampling(); s.First != 2 || s.Thereafter != 3 {
+		t.Errorf("unexpected sampling %+v", s)
+	}
+}
+
+// stringer counts calls of its String method.
+type stringer struct{ calls *int }
+
+func (s stringer) String() string {
+	*s.calls++
+	return "stringer"
+}
+
+func TestLoggerSamplingDefaults(t *testing.T) {
+	var buf syncBuffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetSampling(log.Sampling{Interval: time.Hour})
+	defer logger.SetSampling(log.Sampling{})
+
+	// Expect the first log of each template to be written
+	logger.Err("boom")
+	logger.Err("boom")
+	logger.Emerg("boom")
+	if n := strings.Count(buf.String(), "boom"); n != 2 {
+		t.Errorf("expected 2 logs, got %d in output %q", n, buf.String())
+	}
+	if s := logger.GetSampling(); s.First != 1 {
+		t.Errorf("expected first of 1, got %+v", s)
+	}
+
+	// Expect logs without a template to be dropped before they are formatted
+	var calls int
+	for i := 0; i < 3; i++ {
+		logger.Info(stringer{&calls})
+	}
+	if calls != 1 || strings.Count(buf.String(), "stringer") != 1 {
+		t.Errorf("expected 1 log formatted, got %d in output %q", calls, buf.String())
+	}
+}
+
+func TestLoggerSamplingIdle(t *testing.T) {
+	logger := new(log.Logger)
+	logger.SetOutput(&bytes.Buffer{})
+	logger.SetSampling(log.Sampling{Interval: 10 * time.Millisecond, First: 1})
+
+	// Expect sampling to stop summarizing once no logs are written
+	logger.Info("msg")
+	for start := time.Now(); !summarizing(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("expected summary of sampling to start")
+		}
+	}
+	for start := time.Now(); summarizing(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("expected summary of sampling to stop")
+		}
+	}
+}
+
+// summarizing reports whether any goroutine summarizes sampling.
+func summarizing() bool {
+	buf := make([]byte, 1<<20)
+	return strings.Contains(string(buf[:runtime.Stack(buf, true)]), "log.(*sampler).")
+}
@@ -0,0 +1,405 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the sinks managed by SetOutput and ConnectSyslog.
+const (
+	// OutputSink is the name of the sink writing to the output set by
+	// SetOutput and encoded by the Encoder set by SetEncoder.
+	OutputSink = "output"
+	// SyslogSink is the name of the sink added by ConnectSyslog.
+	SyslogSink = "syslog"
+)
+
+// LevelInherit can be used as the level of a sink to print at and below the
+// level of the Logger, including any component levels. It is the level of
+// the output sink.
+const LevelInherit syslog.Priority = -1
+
+// Sink is a destination of logs. Each Logger writes to one or more sinks, each
+// with its own verbosity level. WriteRecord may be called concurrently and
+// must not retain r or modify r after returning.
+//
+// When removed from a Logger, sinks implementing io.Closer are closed.
+type Sink interface {
+	WriteRecord(r *Record) error
+}
+
+// Reloader is implemented by sinks that can reload their configuration or
+// reopen their files, see (*Logger).Reload.
+type Reloader interface {
+	Reload() error
+}
+
+// WriterSink is a Sink writing records encoded by an Encoder to an io.Writer.
+// Each record is written with a single call to Write.
+type WriterSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc Encoder
+}
+
+// NewWriterSink returns a sink writing records encoded by enc to w. If w is
+// nil then os.Stderr will be used. If enc is nil then a TextEncoder will be
+// used.
+func NewWriterSink(w io.Writer, enc Encoder) *WriterSink {
+	return &WriterSink{w: w, enc: enc}
+}
+
+// WriteRecord implements Sink.
+func (s *WriterSink) WriteRecord(r *Record) error {
+	s.mu.Lock()
+	w, enc := s.w, s.enc
+	s.mu.Unlock()
+	if w == nil {
+		w = os.Stderr
+	}
+	if enc == nil {
+		enc = TextEncoder{}
+	}
+
+	line, err := enc.Encode(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = w.Write(line)
+	return err
+}
+
+func (s *WriterSink) setWriter(w io.Writer) {
+	s.mu.Lock()
+	s.w = w
+	s.mu.Unlock()
+}
+
+func (s *WriterSink) setEncoder(enc Encoder) {
+	s.mu.Lock()
+	s.enc = enc
+	s.mu.Unlock()
+}
+
+// outputSink returns the output sink, adding a WriterSink writing to stderr
+// if it was removed or replacing it if it is not a WriterSink.
+func (l *Logger) outputSink() *WriterSink {
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+
+	var old sinkEntry
+	old.level = LevelInherit
+	if i := l.sinkIndex(OutputSink); i >= 0 {
+		if s, ok := l.sinks[i].sink.(*WriterSink); ok {
+			return s
+		}
+		old, _ = l.removeSink(OutputSink)
+	}
+	s := NewWriterSink(nil, nil)
+	_ = l.addSink(OutputSink, s, old.level)
+	l.sinks[len(l.sinks)-1].dedup = old.dedup.to(s)
+	return s
+}
+
+type sinkEntry struct {
+	name  string
+	sink  Sink
+	level syslog.Priority
+	dedup *dedup // nil unless set by SetSinkDedup
+}
+
+// Must be called before accessing the sinks in order to add the default
+// output sink.
+func (l *Logger) initSinks() {
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	l.sinks = []sinkEntry{{name: OutputSink, sink: NewWriterSink(nil, nil), level: LevelInherit}}
+}
+
+// getSinks returns the current sinks, which must not be modified.
+func (l *Logger) getSinks() []sinkEntry {
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.RLock()
+	defer l.sinksMu.RUnlock()
+	return l.sinks
+}
+
+// AddSink adds a named sink that logs are written to at and below lvl, which
+// takes values syslog.LOG_EMERG...syslog.LOG_DEBUG or LevelInherit. An error
+// is returned if a sink of the same name exists or the name is SyslogSink,
+// which is reserved for ConnectSyslog.
+func (l *Logger) AddSink(name string, s Sink, lvl syslog.Priority) error {
+	if name == SyslogSink {
+		return errReservedSink(name)
+	}
+	l.once.Do(l.initPrinter)
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	return l.addSink(name, s, lvl)
+}
+
+// Only call with a write lock on the sinks mutex
+func (l *Logger) addSink(name string, s Sink, lvl syslog.Priority) error {
+	if l.sinkIndex(name) >= 0 {
+		return fmt.Errorf("sink %q already exists", name)
+	}
+	// copy on write, as getSinks returns the slice without holding the lock
+	sinks := make([]sinkEntry, len(l.sinks), len(l.sinks)+1)
+	copy(sinks, l.sinks)
+	l.sinks = append(sinks, sinkEntry{name: name, sink: s, level: sinkLevel(lvl)})
+	return nil
+}
+
+// RemoveSink removes a named sink, closing it if it implements io.Closer. The
+// syslog sink cannot be removed, use DisconnectSyslog instead.
+func (l *Logger) RemoveSink(name string) error {
+	if name == SyslogSink {
+		return errReservedSink(name)
+	}
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	entry, err := l.removeSink(name)
+	l.sinksMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	entry.dedup.stop()
+	if c, ok := entry.sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func errReservedSink(name string) error {
+	return fmt.Errorf("sink %q is reserved for ConnectSyslog", name)
+}
+
+// Only call with a write lock on the sinks mutex
+func (l *Logger) removeSink(name string) (sinkEntry, error) {
+	i := l.sinkIndex(name)
+	if i < 0 {
+		return sinkEntry{}, fmt.Errorf("sink %q does not exist", name)
+	}
+	entry := l.sinks[i]
+	sinks := make([]sinkEntry, 0, len(l.sinks)-1)
+	sinks = append(sinks, l.sinks[:i]...)
+	l.sinks = append(sinks, l.sinks[i+1:]...)
+	return entry, nil
+}
+
+// SinkNames returns the names of all sinks in the order they were added.
+func (l *Logger) SinkNames() []string {
+	sinks := l.getSinks()
+	names := make([]string, len(sinks))
+	for i, entry := range sinks {
+		names[i] = entry.name
+	}
+	return names
+}
+
+// Reload reloads each sink implementing Reloader, e.g. to pick up rotated TLS
+// certificates. The first error is returned after reloading all sinks.
+func (l *Logger) Reload() error {
+	var first error
+	for _, entry := range l.getSinks() {
+		r, ok := entry.sink.(Reloader)
+		if !ok {
+			continue
+		}
+		if err := r.Reload(); err != nil && first == nil {
+			first = fmt.Errorf("error reloading %s: %v", entry.name, err)
+		}
+	}
+	return first
+}
+
+// SetSinkLevel alters the verbosity level that a named sink writes logs at
+// and below. It takes values syslog.LOG_EMERG...syslog.LOG_DEBUG or
+// LevelInherit. Setting the level of the syslog sink is equivalent to
+// SetSyslogLevel, so an error is returned if it is LevelInherit.
+func (l *Logger) SetSinkLevel(name string, lvl syslog.Priority) error {
+	if name == SyslogSink {
+		if lvl == LevelInherit {
+			return fmt.Errorf("sink %q cannot inherit its level", name)
+		}
+		l.SetSyslogLevel(lvl)
+		return nil
+	}
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	return l.setSinkLevel(name, lvl)
+}
+
+// Only call with a write lock on the sinks mutex
+func (l *Logger) setSinkLevel(name string, lvl syslog.Priority) error {
+	i := l.sinkIndex(name)
+	if i < 0 {
+		return fmt.Errorf("sink %q does not exist", name)
+	}
+	sinks := make([]sinkEntry, len(l.sinks))
+	copy(sinks, l.sinks)
+	sinks[i].level = sinkLevel(lvl)
+	l.sinks = sinks
+	return nil
+}
+
+// GetSinkLevel returns the verbosity level that a named sink writes logs at
+// and below, which may be LevelInherit.
+func (l *Logger) GetSinkLevel(name string) (syslog.Priority, error) {
+	for _, entry := range l.getSinks() {
+		if entry.name == name {
+			return entry.level, nil
+		}
+	}
+	return 0, fmt.Errorf("sink %q does not exist", name)
+}
+
+// Only call with a read lock on the sinks mutex
+func (l *Logger) sinkIndex(name string) int {
+	for i, entry := range l.sinks {
+		if entry.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Helper func to reduce a sink level to LevelInherit or a severity.
+func sinkLevel(lvl syslog.Priority) syslog.Priority {
+	if lvl < 0 {
+		return LevelInherit
+	}
+	return lvl & severityMask
+}
+
+// sinkFilter selects the sinks a log is written to.
+type sinkFilter int
+
+const (
+	allSinks    sinkFilter = iota
+	localSinks             // all but the syslog sink
+	syslogSinks            // only the syslog sink
+)
+
+func (f sinkFilter) match(name string) bool {
+	switch f {
+	case localSinks:
+		return name != SyslogSink
+	case syslogSinks:
+		return name == SyslogSink
+	default:
+		return true
+	}
+}
+
+// enabled reports whether a log of a component, set by the level patterns of
+// the component, with severity p would be written to any sink.
+func (l *Logger) enabled(p syslog.Priority, patterns []string) bool {
+	for _, entry := range l.getSinks() {
+		lvl := entry.level
+		if lvl == LevelInherit {
+			lvl = l.componentLevel(patterns)
+		}
+		if (p & severityMask) <= lvl {
+			return true
+		}
+	}
+	return false
+}
+
+// log writes a message of a component, set by the level patterns of the
+// component, with fields to each sink selected by filter whose level is at
+// or above severity p.
+func (l *Logger) log(p syslog.Priority, patterns []string, fields []Field, msg string, filter sinkFilter) {
+	l.logTime(time.Time{}, p, patterns, fields, msg, filter)
+}
+
+// logTime is log for a message printed at t, or now if t is zero.
+func (l *Logger) logTime(t time.Time, p syslog.Priority, patterns []string, fields []Field, msg string,
+	filter sinkFilter) {
+	var (
+		rec     *Record
+		compLvl syslog.Priority = LevelInherit // looked up once when needed
+	)
+	for _, entry := range l.getSinks() {
+		if !filter.match(entry.name) {
+			continue
+		}
+		lvl := entry.level
+		if lvl == LevelInherit {
+			if compLvl == LevelInherit {
+				compLvl = l.componentLevel(patterns)
+			}
+			lvl = compLvl
+		}
+		if (p & severityMask) > lvl {
+			continue
+		}
+
+		if rec == nil {
+			if t.IsZero() {
+				t = time.Now()
+			}
+			rec = &Record{
+				Time:     t,
+				Priority: syslevel(p, l.GetFacility()),
+				Service:  svcName,
+				PID:      os.Getpid(),
+				Fields:   fields,
+				Message:  strings.TrimSuffix(msg, "\n"),
+			}
+		}
+		var err error
+		if entry.dedup != nil {
+			err = entry.dedup.WriteRecord(rec)
+		} else {
+			err = entry.sink.WriteRecord(rec)
+		}
+		if err != nil {
+			l.sinkError(entry.name, rec, err)
+		}
+	}
+}
+
+// sinkError reports an error writing a record to a sink to the output sink
+// or, if that fails too, with printError.
+func (l *Logger) sinkError(name string, r *Record, err error) {
+	if name != OutputSink {
+		for _, entry := range l.getSinks() {
+			if entry.name != OutputSink {
+				continue
+			}
+			err2 := entry.sink.WriteRecord(&Record{
+				Time:     time.Now(),
+				Priority: r.Priority,
+				Service:  r.Service,
+				PID:      r.PID,
+				Message:  strings.TrimSuffix("error writing to "+name+": "+err.Error(), "\n"),
+			})
+			if err2 == nil {
+				return
+			}
+			err = fmt.Errorf("%v; error writing to %s: %v", err, OutputSink, err2)
+		}
+	}
+	printError("error writing to %s log: %s", name, err)
+}
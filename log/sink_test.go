@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"log/syslog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-ness/common/log"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) WriteRecord(r *log.Record) error {
+	_, err := b.WriteString(r.Message + "\n")
+	return err
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestLoggerSinks(t *testing.T) {
+	var (
+		text, json bytes.Buffer
+		custom     closeBuffer
+		logger     = new(log.Logger)
+	)
+	logger.SetOutput(&text)
+	if err := logger.AddSink("json", log.NewWriterSink(&json, log.JSONEncoder{}), syslog.LOG_DEBUG); err != nil {
+		t.Fatalf("error adding json sink: %v", err)
+	}
+	if err := logger.AddSink("custom", &custom, syslog.LOG_WARNING); err != nil {
+		t.Fatalf("error adding custom sink: %v", err)
+	}
+	if err := logger.AddSink("custom", &custom, syslog.LOG_WARNING); err == nil {
+		t.Errorf("expected error adding sink with existing name")
+	}
+	if err := logger.AddSink(log.SyslogSink, &custom, syslog.LOG_WARNING); err == nil {
+		t.Errorf("expected error adding sink with reserved name")
+	}
+	if err := logger.RemoveSink(log.SyslogSink); err == nil {
+		t.Errorf("expected error removing sink with reserved name")
+	}
+	if n := logger.SyslogDropped(); n != 0 {
+		t.Errorf("expected no dropped syslog messages, got %d", n)
+	}
+	if err := logger.DisconnectSyslog(); err != nil {
+		t.Errorf("unexpected error disconnecting syslog: %v", err)
+	}
+	if names := logger.SinkNames(); !reflect.DeepEqual(names, []string{"output", "json", "custom"}) {
+		t.Errorf("unexpected sink names %v", names)
+	}
+
+	// Expect each sink to filter by its own level
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warning("warning")
+	if lines := strings.Count(text.String(), "\n"); lines != 2 {
+		t.Errorf("expected 2 lines of text output, got %q", text.String())
+	}
+	if lines := strings.Count(json.String(), "\n"); lines != 3 || !strings.HasPrefix(json.String(), "{") {
+		t.Errorf("expected 3 lines of json output, got %q", json.String())
+	}
+	if custom.String() != "warning\n" {
+		t.Errorf("expected only warning in custom output, got %q", custom.String())
+	}
+
+	// Expect inherited level of output to follow the logger
+	text.Reset()
+	logger.SetLevel(syslog.LOG_DEBUG)
+	logger.Debug("debug")
+	if !strings.HasSuffix(text.String(), "debug\n") {
+		t.Errorf("expected debug in text output, got %q", text.String())
+	}
+
+	// Expect removed sinks to be closed and not written to
+	if err := logger.RemoveSink("custom"); err != nil {
+		t.Fatalf("error removing custom sink: %v", err)
+	}
+	if !custom.closed {
+		t.Errorf("expected custom sink to be closed")
+	}
+	logger.Err("error")
+	if strings.Contains(custom.String(), "error") {
+		t.Errorf("expected no output to removed sink, got %q", custom.String())
+	}
+	if err := logger.RemoveSink("custom"); err == nil {
+		t.Errorf("expected error removing sink twice")
+	}
+
+	// Expect SetOutput to re-add a removed output sink
+	text.Reset()
+	if err := logger.RemoveSink(log.OutputSink); err != nil {
+		t.Fatalf("error removing output sink: %v", err)
+	}
+	logger.Info("removed")
+	logger.SetOutput(&text)
+	logger.Info("re-added")
+	if !strings.HasSuffix(text.String(), "re-added\n") || strings.Contains(text.String(), "removed") {
+		t.Errorf("expected only re-added in text output, got %q", text.String())
+	}
+}
+
+func TestLoggerSinkLevel(t *testing.T) {
+	logger := new(log.Logger)
+	if lvl, err := logger.GetSinkLevel(log.OutputSink); err != nil || lvl != log.LevelInherit {
+		t.Errorf("expected inherited output sink level, got %d (%v)", lvl, err)
+	}
+	if err := logger.SetSinkLevel(log.OutputSink, syslog.LOG_ERR); err != nil {
+		t.Fatalf("error setting output sink level: %v", err)
+	}
+	if lvl, _ := logger.GetSinkLevel(log.OutputSink); lvl != syslog.LOG_ERR {
+		t.Errorf("expected output sink level %d, got %d", syslog.LOG_ERR, lvl)
+	}
+	if err := logger.SetSinkLevel("missing", syslog.LOG_ERR); err == nil {
+		t.Errorf("expected error setting level of missing sink")
+	}
+
+	// Expect the syslog sink not to inherit its level
+	logger.SetSyslogLevel(syslog.LOG_WARNING)
+	if err := logger.SetSinkLevel(log.SyslogSink, log.LevelInherit); err == nil {
+		t.Errorf("expected error inheriting level of syslog sink")
+	}
+	if lvl := logger.GetSyslogLevel(); lvl != syslog.LOG_WARNING {
+		t.Errorf("expected syslog level %d, got %d", syslog.LOG_WARNING, lvl)
+	}
+}
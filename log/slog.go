@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"log/syslog"
+	"time"
+)
+
+// SlogHandler is a slog.Handler writing records to a Logger, so that code
+// written against log/slog shares its sinks, levels and components. Levels
+// are mapped to severities as follows, and back by SlogSink:
+//
+//	slog level          severity
+//	< Info              DEBUG
+//	Info, Info+1        INFO
+//	Info+2, Info+3      NOTICE
+//	Warn ... Warn+3     WARNING
+//	Error ... Error+3   ERR
+//	Error+4 ... +7      CRIT
+//	Error+8 ... +11     ALERT
+//	>= Error+12         EMERG
+//
+// Attributes become fields in order, with the keys of attributes in groups
+// prefixed by the names of the groups and a dot, e.g. "req.method".
+type SlogHandler struct {
+	printer Printer
+	fields  []Field // added by WithAttrs
+	prefix  string  // of keys in groups opened by WithGroup
+}
+
+// SlogHandler returns a slog.Handler writing to the Logger of p with the
+// fields and component of p.
+func (p Printer) SlogHandler() *SlogHandler {
+	if p.logger == nil {
+		p = DefaultLogger.printer(p.component, p.fields)
+	}
+	return &SlogHandler{printer: p}
+}
+
+// SlogHandler returns a slog.Handler writing to l, e.g. to use l with
+// slog.New.
+func (l *Logger) SlogHandler() *SlogHandler {
+	l.once.Do(l.initPrinter)
+	return l.Printer.SlogHandler()
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return h.printer.logger.enabled(slogSeverity(lvl), h.printer.patterns)
+}
+
+// Handle implements slog.Handler. The log keeps the time of r.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	l, p := h.printer.logger, slogSeverity(r.Level)
+	if !l.sample(p, h.printer.component, r.Message, nil) {
+		return nil
+	}
+
+	fields := make([]Field, 0, len(h.printer.fields)+len(h.fields)+r.NumAttrs())
+	fields = append(fields, h.printer.fields...)
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	fields = append(fields, l.callerFields(p, 0)...)
+	l.logTime(r.Time, p, h.printer.patterns, fields, r.Message, allSinks)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.fields = make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(h2.fields, h.fields)
+	for _, a := range attrs {
+		h2.fields = appendAttr(h2.fields, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr appends a as fields with keys prefixed by prefix to fields,
+// flattening groups.
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	case slog.KindString:
+		return append(fields, String(prefix+a.Key, v.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(prefix+a.Key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, Uint64(prefix+a.Key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(prefix+a.Key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(prefix+a.Key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(prefix+a.Key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(prefix+a.Key, v.Time()))
+	default:
+		if a.Key == "" && v.Any() == nil {
+			return fields // empty attrs are ignored
+		}
+		return append(fields, Any(prefix+a.Key, v.Any()))
+	}
+}
+
+// slogSeverity returns the syslog severity of a slog level.
+func slogSeverity(lvl slog.Level) syslog.Priority {
+	switch {
+	case lvl < slog.LevelInfo:
+		return syslog.LOG_DEBUG
+	case lvl < slog.LevelInfo+2:
+		return syslog.LOG_INFO
+	case lvl < slog.LevelWarn:
+		return syslog.LOG_NOTICE
+	case lvl < slog.LevelError:
+		return syslog.LOG_WARNING
+	case lvl < slog.LevelError+4:
+		return syslog.LOG_ERR
+	case lvl < slog.LevelError+8:
+		return syslog.LOG_CRIT
+	case lvl < slog.LevelError+12:
+		return syslog.LOG_ALERT
+	default:
+		return syslog.LOG_EMERG
+	}
+}
+
+// slogLevels are the slog levels of syslog severities, indexed by severity.
+var slogLevels = [...]slog.Level{
+	syslog.LOG_EMERG:   slog.LevelError + 12,
+	syslog.LOG_ALERT:   slog.LevelError + 8,
+	syslog.LOG_CRIT:    slog.LevelError + 4,
+	syslog.LOG_ERR:     slog.LevelError,
+	syslog.LOG_WARNING: slog.LevelWarn,
+	syslog.LOG_NOTICE:  slog.LevelInfo + 2,
+	syslog.LOG_INFO:    slog.LevelInfo,
+	syslog.LOG_DEBUG:   slog.LevelDebug,
+}
+
+// SlogSink is a Sink forwarding records to a slog.Handler, e.g. to add the
+// handler of another library to a Logger with AddSink. Severities are mapped
+// to levels as described by SlogHandler and fields become attributes.
+type SlogSink struct {
+	h slog.Handler
+}
+
+// NewSlogSink returns a sink forwarding records to h.
+func NewSlogSink(h slog.Handler) *SlogSink {
+	return &SlogSink{h: h}
+}
+
+// WriteRecord implements Sink.
+func (s *SlogSink) WriteRecord(r *Record) error {
+	ctx := context.Background()
+	lvl := slogLevels[r.Priority&severityMask]
+	if !s.h.Enabled(ctx, lvl) {
+		return nil
+	}
+
+	rec := slog.NewRecord(r.Time, lvl, r.Message, 0)
+	for _, f := range r.Fields {
+		rec.AddAttrs(fieldAttr(f))
+	}
+	return s.h.Handle(ctx, rec)
+}
+
+// fieldAttr returns f as a slog.Attr.
+func fieldAttr(f Field) slog.Attr {
+	switch v := f.Value().(type) {
+	case string:
+		return slog.String(f.Key, v)
+	case int64:
+		return slog.Int64(f.Key, v)
+	case uint64:
+		return slog.Uint64(f.Key, v)
+	case float64:
+		return slog.Float64(f.Key, v)
+	case bool:
+		return slog.Bool(f.Key, v)
+	case time.Duration:
+		return slog.Duration(f.Key, v)
+	case time.Time:
+		return slog.Time(f.Key, v)
+	default:
+		return slog.Any(f.Key, v)
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+//go:build go1.21
+// +build go1.21
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetComponentLevel("proxy", syslog.LOG_NOTICE)
+	sl := slog.New(logger.Component("proxy").SlogHandler())
+
+	// Expect levels to follow the component
+	if sl.Enabled(context.Background(), slog.LevelInfo) {
+		t.Errorf("expected INFO disabled")
+	}
+	sl.Info("hidden")
+	sl.Log(context.Background(), slog.LevelInfo+2, "notice")
+	sl.With("a", 1).WithGroup("req").Warn("hello", "method", "GET", slog.Group("peer", "ip", "10.0.0.1"), slog.Attr{})
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines of output, got %q", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "<133>") || !strings.HasSuffix(lines[0], "]: [component=proxy] notice") {
+		t.Errorf("unexpected notice %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "<132>") ||
+		!strings.HasSuffix(lines[1], "]: [component=proxy] [a=1] [req.method=GET] [req.peer.ip=10.0.0.1] hello") {
+		t.Errorf("unexpected warning %q", lines[1])
+	}
+
+	// Expect the caller of slog to be found
+	buf.Reset()
+	logger.SetCaller(true)
+	sl.Error("boom")
+	if !strings.Contains(buf.String(), fmt.Sprintf("[caller=log/slog_test.go:%d]", line()-1)) {
+		t.Errorf("expected caller in output %q", buf.String())
+	}
+}
+
+func TestSlogHandlerTime(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	h := logger.SlogHandler()
+
+	// Expect the time of the record rather than when it is handled
+	at := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.Local)
+	if err := h.Handle(context.Background(), slog.NewRecord(at, slog.LevelInfo, "late", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if expect := at.Format(time.Stamp); !strings.Contains(buf.String(), expect) {
+		t.Errorf("expected %q in output %q", expect, buf.String())
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&bytes.Buffer{})
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	if err := logger.AddSink("slog", log.NewSlogSink(h), syslog.LOG_DEBUG); err != nil {
+		t.Fatalf("error adding sink: %v", err)
+	}
+
+	logger.With(log.Int("n", 1), log.Bool("ok", true)).Notice("first")
+	logger.Component("proxy").Debugf("second %d", 2)
+	logger.Crit("third")
+	expect := "level=INFO+2 msg=first n=1 ok=true\n" +
+		"level=DEBUG msg=\"second 2\" component=proxy\n" +
+		"level=ERROR+4 msg=third\n"
+	if buf.String() != expect {
+		t.Errorf("expected output %q, got %q", expect, buf.String())
+	}
+}
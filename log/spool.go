@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolExt = ".spool"
+	// defaultSpoolSize is the total size of segments used when none is given.
+	defaultSpoolSize = 64 << 20
+	// minSegmentSize is the smallest size segments are rotated at and the
+	// smallest total size of segments.
+	minSegmentSize = 4 << 10
+)
+
+// spoolEntry is a syslog message that could not be sent when logged, stored
+// as a line of JSON.
+type spoolEntry struct {
+	Time     time.Time       `json:"time"`
+	Priority syslog.Priority `json:"pri"`
+	SD       string          `json:"sd,omitempty"`
+	Message  string          `json:"msg"`
+
+	line int // index in its segment
+}
+
+// spoolSegment is a file of spooled entries, named by its sequence number.
+type spoolSegment struct {
+	seq     uint64
+	size    int64
+	lines   int // complete entries
+	modTime time.Time
+}
+
+// spool stores syslog messages in append-only segment files in a directory.
+// Each entry is written with a single write, so at most the entry being
+// written is lost if the process crashes, and a partially written entry is
+// skipped when read. Entries are removed a segment at a time once sent, so
+// sending is at-least-once: entries of a partially sent segment are sent
+// again after a restart.
+type spool struct {
+	dir         string
+	maxSize     int64
+	maxAge      time.Duration
+	segmentSize int64
+
+	mu       sync.Mutex
+	segments []spoolSegment // oldest first, the last may be active
+	active   *os.File       // last segment, open for appending
+	sent     int            // lines of the oldest segment already sent
+	counted  int            // lines of the oldest segment counted if dropped
+	dropped  uint64
+}
+
+// openSpool opens a spool in dir, creating it if necessary, keeping the total
+// size of segments at or below maxSize bytes and dropping entries older than
+// maxAge. A maxSize of zero selects a default of 64MiB and a smaller maxSize
+// than minSegmentSize is rounded up to it, so that a segment fits. A maxAge of
+// zero disables dropping entries by age. Segments left by a previous process
+// are kept to be sent.
+func openSpool(dir string, maxSize int64, maxAge time.Duration) (*spool, error) {
+	if maxSize <= 0 {
+		maxSize = defaultSpoolSize
+	}
+	if maxSize < minSegmentSize {
+		maxSize = minSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating spool dir: %v", err)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool dir: %v", err)
+	}
+
+	s := &spool{dir: dir, maxSize: maxSize, maxAge: maxAge, segmentSize: maxSize / 8}
+	if s.segmentSize < minSegmentSize {
+		s.segmentSize = minSegmentSize
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, spoolExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seg := spoolSegment{seq: seq, size: info.Size(), modTime: info.ModTime()}
+		seg.lines = s.countLines(seg)
+		s.segments = append(s.segments, seg)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	return s, nil
+}
+
+func (s *spool) path(seg spoolSegment) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seg.seq, spoolExt))
+}
+
+// empty reports whether there are no entries to be sent.
+func (s *spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments) == 0
+}
+
+// append stores an entry, starting a new segment if the active one is full
+// and removing the oldest segments if over the size or age limits. An entry
+// larger than the size limit is dropped.
+func (s *spool) append(e spoolEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if int64(len(line)) > s.maxSize {
+		s.dropped++
+		return fmt.Errorf("log of %d bytes exceeds spool size", len(line))
+	}
+
+	if s.active == nil || s.segments[len(s.segments)-1].size+int64(len(line)) > s.segmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.active.Write(line); err != nil {
+		return fmt.Errorf("error writing to spool: %v", err)
+	}
+	last := &s.segments[len(s.segments)-1]
+	last.size += int64(len(line))
+	last.lines++
+	last.modTime = time.Now()
+
+	s.trim()
+	return nil
+}
+
+// Only call with a lock on the mutex
+func (s *spool) rotate() error {
+	if err := s.closeActive(); err != nil {
+		return err
+	}
+	seg := spoolSegment{seq: 1, modTime: time.Now()}
+	if n := len(s.segments); n > 0 {
+		seg.seq = s.segments[n-1].seq + 1
+	}
+	f, err := os.OpenFile(s.path(seg), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating spool segment: %v", err)
+	}
+	s.active = f
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+// Only call with a lock on the mutex
+func (s *spool) closeActive() error {
+	if s.active == nil {
+		return nil
+	}
+	// Sync so that a full segment survives a crash of the system too
+	err := s.active.Sync()
+	if cerr := s.active.Close(); err == nil {
+		err = cerr
+	}
+	s.active = nil
+	if err != nil {
+		return fmt.Errorf("error closing spool segment: %v", err)
+	}
+	return nil
+}
+
+// Only call with a lock on the mutex
+func (s *spool) trim() {
+	var size int64
+	for _, seg := range s.segments {
+		size += seg.size
+	}
+	for len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if size <= s.maxSize && (s.maxAge <= 0 || time.Since(oldest.modTime) <= s.maxAge) {
+			break
+		}
+		size -= oldest.size
+		if oldest.lines > s.sent {
+			s.dropped += uint64(oldest.lines - s.sent)
+		}
+		s.removeOldest()
+	}
+}
+
+// countLines returns the number of complete entries of a segment left by a
+// previous process.
+func (s *spool) countLines(seg spoolSegment) int {
+	b, err := ioutil.ReadFile(s.path(seg))
+	if err != nil {
+		return 0
+	}
+	return bytes.Count(b, []byte{'\n'})
+}
+
+// Only call with a lock on the mutex
+func (s *spool) removeOldest() {
+	if len(s.segments) == 1 {
+		_ = s.closeActive()
+	}
+	_ = os.Remove(s.path(s.segments[0]))
+	s.segments = s.segments[1:]
+	s.sent, s.counted = 0, 0
+}
+
+// oldest returns the sequence number and unsent entries of the oldest
+// segment, closing it if it is active so that new entries are appended to a
+// new segment. Entries older than the age limit and segments that cannot be
+// read are dropped.
+func (s *spool) oldest() (uint64, []spoolEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.segments) > 0 {
+		if len(s.segments) == 1 {
+			_ = s.closeActive()
+		}
+		seg := s.segments[0]
+		if entries := s.read(seg); len(entries) > 0 {
+			return seg.seq, entries
+		}
+		s.removeOldest()
+	}
+	return 0, nil
+}
+
+// Only call with a lock on the mutex
+func (s *spool) read(seg spoolSegment) []spoolEntry {
+	f, err := os.Open(s.path(seg))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var (
+		entries []spoolEntry
+		r       = bufio.NewReader(f)
+		n       int
+	)
+	for ; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// Ignore a partial entry written before a crash
+			break
+		}
+		if n < s.sent {
+			continue
+		}
+		var e spoolEntry
+		if err := json.Unmarshal(line, &e); err != nil ||
+			(s.maxAge > 0 && time.Since(e.Time) > s.maxAge) {
+			if n >= s.counted {
+				s.dropped++
+			}
+			continue
+		}
+		e.line = n
+		entries = append(entries, e)
+	}
+	s.counted = n
+	return entries
+}
+
+// done records that the entries of segment seq returned by oldest were sent
+// up to, but not including, the entry at line, removing the segment if all
+// were sent. The rest are returned again by oldest.
+func (s *spool) done(seq uint64, line int, all bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) == 0 || s.segments[0].seq != seq {
+		return // removed by trim
+	}
+	if all {
+		s.removeOldest()
+		return
+	}
+	s.sent = line
+}
+
+// droppedEntries returns the number of entries dropped due to the size or age
+// limits or because they were corrupt.
+func (s *spool) droppedEntries() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// close closes the active segment, keeping all entries to be sent by the next
+// spool opened in the same directory.
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeActive()
+}
@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempSpoolDir returns a new dir for a spool and a func removing it.
+func tempSpoolDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("error creating spool dir: %v", err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func mustOpenSpool(t *testing.T, dir string, maxSize int64, maxAge time.Duration) *spool {
+	t.Helper()
+	s, err := openSpool(dir, maxSize, maxAge)
+	if err != nil {
+		t.Fatalf("error opening spool: %v", err)
+	}
+	return s
+}
+
+func mustAppend(t *testing.T, s *spool, msg string) {
+	t.Helper()
+	if err := s.append(spoolEntry{Time: time.Now(), Priority: syslog.LOG_INFO, Message: msg}); err != nil {
+		t.Fatalf("error appending to spool: %v", err)
+	}
+}
+
+// expectMessages checks the messages of entries returned by oldest.
+func expectMessages(t *testing.T, entries []spoolEntry, expect ...string) {
+	t.Helper()
+	var msgs []string
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+	if strings.Join(msgs, ",") != strings.Join(expect, ",") {
+		t.Errorf("expected messages %q, got %q", expect, msgs)
+	}
+}
+
+// spoolFiles returns the total size and number of lines of the segments in
+// dir.
+func spoolFiles(t *testing.T, dir string) (size int64, lines int) {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+spoolExt))
+	if err != nil {
+		t.Fatalf("error listing spool dir: %v", err)
+	}
+	for _, m := range matches {
+		b, err := ioutil.ReadFile(m)
+		if err != nil {
+			t.Fatalf("error reading segment: %v", err)
+		}
+		size += int64(len(b))
+		lines += bytes.Count(b, []byte{'\n'})
+	}
+	return size, lines
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	dir, cleanup := tempSpoolDir(t)
+	defer cleanup()
+	const maxSize = 2 * minSegmentSize
+	s := mustOpenSpool(t, dir, maxSize, 0)
+	defer s.close()
+
+	// Expect the oldest segments to be removed and their entries counted
+	const n = 200
+	for i := 0; i < n; i++ {
+		mustAppend(t, s, fmt.Sprintf("%03d %s", i, strings.Repeat("x", 100)))
+	}
+	size, lines := spoolFiles(t, dir)
+	if size > maxSize {
+		t.Errorf("expected at most %d bytes spooled, got %d", maxSize, size)
+	}
+	if lines == 0 || lines == n {
+		t.Fatalf("expected some of %d entries dropped, got %d kept", n, lines)
+	}
+	if dropped := s.droppedEntries(); dropped != uint64(n-lines) {
+		t.Errorf("expected %d entries dropped, got %d", n-lines, dropped)
+	}
+
+	// Expect the newest entries to be kept in order
+	_, entries := s.oldest()
+	if len(entries) == 0 || !strings.HasPrefix(entries[0].Message, fmt.Sprintf("%03d ", n-lines)) {
+		t.Errorf("expected oldest kept entry %d, got %+v", n-lines, entries)
+	}
+
+	// Expect entries already sent not to be counted when dropped
+	seq, entries := s.oldest()
+	s.done(seq, entries[len(entries)-1].line, false)
+	before := s.droppedEntries()
+	for i := 0; i < n; i++ {
+		mustAppend(t, s, strings.Repeat("y", 100))
+	}
+	if _, lines2 := spoolFiles(t, dir); s.droppedEntries()-before != uint64(lines+n-lines2-len(entries)+1) {
+		t.Errorf("expected %d entries dropped, got %d", lines+n-lines2-len(entries)+1, s.droppedEntries()-before)
+	}
+}
+
+func TestSpoolSmallMaxSize(t *testing.T) {
+	dir, cleanup := tempSpoolDir(t)
+	defer cleanup()
+	s := mustOpenSpool(t, dir, 100, 0)
+	defer s.close()
+
+	// Expect the size to be rounded up to fit a segment
+	for i := 0; i < 100; i++ {
+		mustAppend(t, s, strings.Repeat("x", 100))
+	}
+	if size, _ := spoolFiles(t, dir); size > minSegmentSize {
+		t.Errorf("expected at most %d bytes spooled, got %d", minSegmentSize, size)
+	}
+
+	// Expect an entry larger than the limit to be dropped
+	before := s.droppedEntries()
+	if err := s.append(spoolEntry{Time: time.Now(), Message: strings.Repeat("x", minSegmentSize)}); err == nil {
+		t.Error("expected error appending entry larger than spool")
+	}
+	if dropped := s.droppedEntries() - before; dropped != 1 {
+		t.Errorf("expected 1 entry dropped, got %d", dropped)
+	}
+	if size, _ := spoolFiles(t, dir); size > minSegmentSize {
+		t.Errorf("expected at most %d bytes spooled, got %d", minSegmentSize, size)
+	}
+}
+
+func TestSpoolMaxAge(t *testing.T) {
+	dir, cleanup := tempSpoolDir(t)
+	defer cleanup()
+	s := mustOpenSpool(t, dir, 0, time.Hour)
+
+	// Expect entries older than the limit to be dropped when read
+	if err := s.append(spoolEntry{Time: time.Now().Add(-2 * time.Hour), Message: "old"}); err != nil {
+		t.Fatalf("error appending to spool: %v", err)
+	}
+	mustAppend(t, s, "new")
+	_, entries := s.oldest()
+	expectMessages(t, entries, "new")
+	if dropped := s.droppedEntries(); dropped != 1 {
+		t.Errorf("expected 1 entry dropped, got %d", dropped)
+	}
+
+	// Expect them to be counted once when read again
+	_, entries = s.oldest()
+	expectMessages(t, entries, "new")
+	if dropped := s.droppedEntries(); dropped != 1 {
+		t.Errorf("expected 1 entry dropped, got %d", dropped)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("error closing spool: %v", err)
+	}
+
+	// Expect a segment not written to within the limit to be removed
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, fmt.Sprintf("%020d%s", 1, spoolExt)), old, old); err != nil {
+		t.Fatalf("error aging segment: %v", err)
+	}
+	s = mustOpenSpool(t, dir, 0, time.Hour)
+	defer s.close()
+	mustAppend(t, s, "newer")
+	_, entries = s.oldest()
+	expectMessages(t, entries, "newer")
+	if dropped := s.droppedEntries(); dropped != 2 {
+		t.Errorf("expected 2 entries dropped, got %d", dropped)
+	}
+}
+
+func TestSpoolPartialEntry(t *testing.T) {
+	dir, cleanup := tempSpoolDir(t)
+	defer cleanup()
+
+	// Write a segment ending with an entry cut by a crash
+	segment := `{"time":"2020-01-02T03:04:05Z","pri":6,"msg":"first"}` + "\n" +
+		`{"time":"2020-01-02T03:04:06Z","pri":6,"msg":"second"}` + "\n" +
+		`{"time":"2020-01-02T03:04:07Z","pri":6,"ms`
+	if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d%s", 7, spoolExt)), []byte(segment), 0600); err != nil {
+		t.Fatalf("error writing segment: %v", err)
+	}
+	s := mustOpenSpool(t, dir, 0, 0)
+	defer s.close()
+
+	// Expect the partial entry to be skipped and new entries not to be
+	// appended to it
+	mustAppend(t, s, "third")
+	seq, entries := s.oldest()
+	expectMessages(t, entries, "first", "second")
+	if seq != 7 {
+		t.Errorf("expected segment 7, got %d", seq)
+	}
+	if dropped := s.droppedEntries(); dropped != 0 {
+		t.Errorf("expected no entries dropped, got %d", dropped)
+	}
+	s.done(seq, 0, true)
+	_, entries = s.oldest()
+	expectMessages(t, entries, "third")
+}
+
+func TestSpoolReopen(t *testing.T) {
+	dir, cleanup := tempSpoolDir(t)
+	defer cleanup()
+	s := mustOpenSpool(t, dir, 0, 0)
+	at := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	if err := s.append(spoolEntry{Time: at, Priority: syslog.LOG_ERR, SD: "[sd]", Message: "first"}); err != nil {
+		t.Fatalf("error appending to spool: %v", err)
+	}
+	mustAppend(t, s, "second")
+	if err := s.close(); err != nil {
+		t.Fatalf("error closing spool: %v", err)
+	}
+
+	// Expect the entries left by a previous spool to be read in order and
+	// new entries to be appended after them
+	s = mustOpenSpool(t, dir, 0, 0)
+	defer s.close()
+	if s.empty() {
+		t.Fatal("expected entries of previous spool")
+	}
+	mustAppend(t, s, "third")
+	seq, entries := s.oldest()
+	expectMessages(t, entries, "first", "second")
+	if e := entries[0]; !e.Time.Equal(at) || e.Priority != syslog.LOG_ERR || e.SD != "[sd]" {
+		t.Errorf("expected entry to be kept as appended, got %+v", e)
+	}
+	s.done(seq, 0, true)
+	seq, entries = s.oldest()
+	expectMessages(t, entries, "third")
+	s.done(seq, 0, true)
+	if !s.empty() {
+		t.Error("expected spool to be empty")
+	}
+}
+
+func TestSpoolPartialSend(t *testing.T) {
+	dir, cleanup := tempSpoolDir(t)
+	defer cleanup()
+	s := mustOpenSpool(t, dir, 0, 0)
+	for _, msg := range []string{"first", "second", "third"} {
+		mustAppend(t, s, msg)
+	}
+
+	// Expect the entries not sent to be returned again
+	seq, entries := s.oldest()
+	s.done(seq, entries[1].line, false)
+	seq2, entries := s.oldest()
+	expectMessages(t, entries, "second", "third")
+	if seq2 != seq {
+		t.Errorf("expected segment %d, got %d", seq, seq2)
+	}
+	s.done(seq, entries[1].line, false)
+	if err := s.close(); err != nil {
+		t.Fatalf("error closing spool: %v", err)
+	}
+
+	// Expect the whole segment to be sent again after a restart
+	s = mustOpenSpool(t, dir, 0, 0)
+	defer s.close()
+	_, entries = s.oldest()
+	expectMessages(t, entries, "first", "second", "third")
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/syslog"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// maxLineSize is the length a partial line is written at by a LineWriter.
+const maxLineSize = 64 << 10
+
+// stdLogCaptures is the number of active captures of the standard library's
+// global logger by CaptureStdLog, updated atomically.
+var stdLogCaptures int32
+
+// printError reports an error of this package that cannot be logged to a
+// sink. It is written to the standard library's global logger unless that is
+// captured, in which case writing to it from within its own output would
+// deadlock, so it is written to stderr instead.
+func printError(format string, a ...interface{}) {
+	if atomic.LoadInt32(&stdLogCaptures) > 0 {
+		fmt.Fprintf(os.Stderr, format+"\n", a...)
+		return
+	}
+	log.Printf(format, a...)
+}
+
+// LineWriter is an io.Writer splitting its input into lines, each logged by a
+// Printer as a message at a fixed severity. Carriage returns before newlines
+// and empty lines are dropped. Lines longer than 64KiB are split.
+type LineWriter struct {
+	p   Printer
+	lvl syslog.Priority
+
+	mu  sync.Mutex
+	buf []byte // partial line
+}
+
+// Writer returns a LineWriter logging lines with severity lvl and the fields
+// and component of p, e.g. to capture the output of another library.
+func (p Printer) Writer(lvl syslog.Priority) *LineWriter {
+	return &LineWriter{p: p, lvl: lvl}
+}
+
+// Writer returns a LineWriter logging lines with severity lvl to l.
+func (l *Logger) Writer(lvl syslog.Priority) *LineWriter {
+	l.once.Do(l.initPrinter)
+	return l.Printer.Writer(lvl)
+}
+
+// Write implements io.Writer, logging each complete line of b and keeping any
+// partial line until it is completed or Close is called.
+func (w *LineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(b)
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, b...)
+			if len(w.buf) >= maxLineSize {
+				w.flush()
+			}
+			break
+		}
+		w.buf = append(w.buf, b[:i]...)
+		b = b[i+1:]
+		w.flush()
+	}
+	return n, nil
+}
+
+// Close implements io.Closer by logging any partial line.
+func (w *LineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush()
+	return nil
+}
+
+// Only call with a lock on the mutex
+func (w *LineWriter) flush() {
+	line := bytes.TrimSuffix(w.buf, []byte{'\r'})
+	if len(line) > 0 {
+		w.p.Print(w.lvl, string(line))
+	}
+	w.buf = w.buf[:0]
+}
+
+// StdLogger returns a standard library Logger logging each message with
+// severity lvl to l, e.g. for http.Server.ErrorLog.
+func (l *Logger) StdLogger(lvl syslog.Priority) *log.Logger {
+	return log.New(l.Writer(lvl), "", 0)
+}
+
+// CaptureStdLog redirects the output of the standard library's global logger
+// to l at severity lvl, so that libraries using it share the sinks of l. Its
+// prefix and flags are cleared, as l adds its own timestamp. The returned
+// func restores the previous output, prefix and flags.
+func (l *Logger) CaptureStdLog(lvl syslog.Priority) (restore func()) {
+	out, prefix, flags := log.Writer(), log.Prefix(), log.Flags()
+	w := l.Writer(lvl)
+	atomic.AddInt32(&stdLogCaptures, 1)
+	log.SetOutput(w)
+	log.SetPrefix("")
+	log.SetFlags(0)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			log.SetOutput(out)
+			log.SetPrefix(prefix)
+			log.SetFlags(flags)
+			_ = w.Close()
+			atomic.AddInt32(&stdLogCaptures, -1)
+		})
+	}
+}
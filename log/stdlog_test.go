@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdlog "log"
+	"log/syslog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+
+	w := logger.Component("lib").Writer(syslog.LOG_WARNING)
+	for _, s := range []string{"first\nsec", "ond\r\n\n", "partial"} {
+		if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+			t.Errorf("unexpected write result %d, %v", n, err)
+		}
+	}
+	if strings.Contains(buf.String(), "partial") {
+		t.Errorf("expected partial line to be kept, got %q", buf.String())
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("error closing writer: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	expect := []string{"first", "second", "partial"}
+	if len(lines) != len(expect) {
+		t.Fatalf("expected %d lines, got %q", len(expect), buf.String())
+	}
+	for i := range expect {
+		if !strings.HasPrefix(lines[i], "<132>") || !strings.HasSuffix(lines[i], "]: [component=lib] "+expect[i]) {
+			t.Errorf("unexpected line %q", lines[i])
+		}
+	}
+}
+
+func TestLoggerStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+
+	logger.StdLogger(syslog.LOG_ERR).Printf("error %d", 1)
+	if !strings.HasPrefix(buf.String(), "<131>") || !strings.HasSuffix(buf.String(), "]: error 1\n") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestLoggerCaptureStdLog(t *testing.T) {
+	var buf, prev bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetCaller(true)
+
+	stdlog.SetOutput(&prev)
+	defer stdlog.SetOutput(os.Stderr)
+	restore := logger.CaptureStdLog(syslog.LOG_NOTICE)
+	stdlog.Printf("from %s", "library")
+	n := line() - 1
+	restore()
+	stdlog.Print("after")
+
+	expect := fmt.Sprintf("[caller=log/stdlog_test.go:%d] [func=log_test.TestLoggerCaptureStdLog] from library\n", n)
+	if !strings.HasPrefix(buf.String(), "<133>") || !strings.HasSuffix(buf.String(), expect) {
+		t.Errorf("expected %q in output %q", expect, buf.String())
+	}
+	if !strings.HasSuffix(prev.String(), " after\n") {
+		t.Errorf("expected output restored, got %q", prev.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestLoggerCaptureStdLogSinkError(t *testing.T) {
+	logger := new(log.Logger)
+	logger.SetOutput(failingWriter{})
+	defer stdlog.SetOutput(os.Stderr)
+	restore := logger.CaptureStdLog(syslog.LOG_INFO)
+	defer restore()
+
+	// Expect the error writing the output not to be reported to the captured
+	// standard logger, which would deadlock
+	done := make(chan struct{})
+	go func() {
+		stdlog.Print("x")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock reporting error of captured standard logger")
+	}
+}
@@ -0,0 +1,718 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/syslog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	slog "github.com/open-ness/common/log/syslog"
+)
+
+// SyslogOption configures a syslog connection made by ConnectSyslog or
+// ConnectSyslogTLS.
+type SyslogOption func(*syslogConfig)
+
+type syslogConfig struct {
+	format   slog.Format
+	framing  slog.Framing
+	sdID     string
+	level    syslog.Priority
+	levelSet bool
+
+	async     bool
+	queueSize int
+	overflow  OverflowPolicy
+
+	minBackoff, maxBackoff time.Duration
+
+	spoolDir     string
+	spoolMaxSize int64
+	spoolMaxAge  time.Duration
+
+	certFile, keyFile, caFile string
+	tlsFiles                  *tlsFiles // loaded by connect
+}
+
+// Default delays between attempts to re-dial a broken syslog connection.
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// dropReportInterval is the least time between errors reporting logs dropped
+// while a synchronous syslog connection is re-dialed.
+const dropReportInterval = 10 * time.Second
+
+// SyslogLevel sets the verbosity level that logs will be sent to syslog at
+// and below, see SetSyslogLevel.
+func SyslogLevel(p syslog.Priority) SyslogOption {
+	return func(c *syslogConfig) {
+		c.level = p
+		c.levelSet = true
+	}
+}
+
+// SyslogFormat selects the wire format of messages sent to syslog. The
+// default is slog.RFC3164.
+func SyslogFormat(f slog.Format) SyslogOption {
+	return func(c *syslogConfig) { c.format = f }
+}
+
+// SyslogFraming selects how messages sent to syslog over TCP or TLS are
+// delimited. The default is slog.NonTransparent, which splits messages
+// containing newlines into several at the server. slog.OctetCounting, as
+// specified by RFC 6587, keeps them whole.
+func SyslogFraming(fr slog.Framing) SyslogOption {
+	return func(c *syslogConfig) { c.framing = fr }
+}
+
+// SyslogStructuredData sends the fields of each Printer to syslog as an RFC
+// 5424 SD-ELEMENT with the given SD-ID, e.g. "fields@32473", instead of as a
+// prefix of the message. It implies the slog.RFC5424 format. Local output is
+// not affected.
+func SyslogStructuredData(sdID string) SyslogOption {
+	return func(c *syslogConfig) {
+		c.format = slog.RFC5424
+		c.sdID = sdID
+	}
+}
+
+// SyslogAsync sends logs to syslog from a queue of size records drained by a
+// background goroutine, so that a slow or unreachable syslog service does not
+// block logging. Logs that do not fit in the queue are handled by policy and
+// counted by SyslogDropped. Queued logs are sent before DisconnectSyslog
+// returns.
+func SyslogAsync(size int, policy OverflowPolicy) SyslogOption {
+	return func(c *syslogConfig) {
+		c.async = true
+		c.queueSize = size
+		c.overflow = policy
+	}
+}
+
+// SyslogBackoff sets the delays between attempts to re-dial a broken syslog
+// connection in the background. The delay starts at min and doubles after
+// each failed attempt up to max, with random jitter of up to half the delay.
+// The defaults are 100ms and 30s.
+func SyslogBackoff(min, max time.Duration) SyslogOption {
+	return func(c *syslogConfig) {
+		if min > 0 {
+			c.minBackoff = min
+		}
+		if max >= c.minBackoff {
+			c.maxBackoff = max
+		} else {
+			c.maxBackoff = c.minBackoff
+		}
+	}
+}
+
+// SyslogSpool stores logs in segment files in dir while syslog is
+// unreachable and sends them in order, with their original timestamps, once
+// it is re-dialed. The oldest logs are dropped to keep the files at or below
+// maxSize bytes, 64MiB if zero and rounded up to 4KiB if smaller, and logs
+// older than maxAge, if non-zero, are dropped. Logs spooled but not sent
+// before exiting are sent by the next connection using dir.
+func SyslogSpool(dir string, maxSize int64, maxAge time.Duration) SyslogOption {
+	return func(c *syslogConfig) {
+		c.spoolDir = dir
+		c.spoolMaxSize = maxSize
+		c.spoolMaxAge = maxAge
+	}
+}
+
+// ConnectSyslog connects to a remote syslog. If addr is an empty string, it
+// will connect to the local syslog service.
+//
+// If already connected, the connection is replaced once the new one has been
+// dialed. Logs queued by the SyslogAsync option are sent to the new
+// connection, unless the delivery options differ, in which case they are
+// sent to the old one before it is closed. If the connection breaks, it is
+// re-dialed in the background as configured by SyslogBackoff. Meanwhile logs
+// are spooled with SyslogSpool, queued with SyslogAsync or dropped, which is
+// reported to the output sink at most every 10 seconds. Only a spool keeps
+// logs through an outage of any length.
+func (l *Logger) ConnectSyslog(addr string, opts ...SyslogOption) error {
+	net := "udp"
+	if addr == "" {
+		net = ""
+	}
+	return l.connect(net, addr, nil, slog.DialTLS, opts)
+}
+
+// ConnectSyslogTLS connects to a remote syslog, performing a TLS client
+// handshake. This is always done over TCP and the addr cannot be empty (in an
+// attempt to connect to the local syslog service). See ConnectSyslog for
+// replacing and re-dialing connections.
+//
+// If conf is nil, TLS 1.2 or later is required and the server certificate is
+// verified against the host of addr with the system roots, as required by RFC
+// 5425. Client certificates can be loaded from files with SyslogTLSFiles.
+func (l *Logger) ConnectSyslogTLS(addr string, conf *tls.Config, opts ...SyslogOption) error {
+	return l.connect("tcp", addr, syslogTLSConfig(addr, conf), slog.DialTLS, opts)
+}
+
+func (l *Logger) connect(net, addr string, conf *tls.Config,
+	dial func(string, string, syslog.Priority, string, *tls.Config) (*slog.Writer, error),
+	opts []SyslogOption) error {
+	l.once.Do(l.initPrinter)
+	l.sinksOnce.Do(l.initSinks)
+
+	cfg := syslogConfig{minBackoff: defaultMinBackoff, maxBackoff: defaultMaxBackoff}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.sdID != "" {
+		if err := slog.ValidSDID(cfg.sdID); err != nil {
+			return err
+		}
+	}
+	if cfg.certFile != "" || cfg.keyFile != "" || cfg.caFile != "" {
+		if conf == nil {
+			return fmt.Errorf("TLS files require ConnectSyslogTLS")
+		}
+		cfg.tlsFiles = &tlsFiles{base: conf, certFile: cfg.certFile, keyFile: cfg.keyFile, caFile: cfg.caFile}
+		var err error
+		if conf, err = cfg.tlsFiles.load(); err != nil {
+			return err
+		}
+	}
+
+	// Get syslog facility and combine with INFO level default logging.
+	// DEBUG will be used for syslogW.Write, which won't be called.
+	l.priorityMu.RLock()
+	priority := syslevel(syslog.LOG_DEBUG, l.getFacility())
+	l.priorityMu.RUnlock()
+
+	// Dial syslog without holding the sinks lock, as it blocks all logging.
+	// Failed writes are not retried by the writer, but re-dialed in the
+	// background by the sink.
+	w, err := dial(net, addr, priority, svcName, conf)
+	if err != nil {
+		return err
+	}
+	w.SetFormat(cfg.format)
+	w.SetFraming(cfg.framing)
+	w.SetRetry(false)
+
+	l.sinksMu.Lock()
+	if cfg.levelSet {
+		l.setSyslogLevel(cfg.level)
+	}
+
+	// Replace the writer of an existing connection in place, so that logs
+	// queued by an asynchronous connection are sent to the new one.
+	var (
+		old      Sink
+		oldDedup *dedup
+		sp       *spool
+	)
+	if i := l.sinkIndex(SyslogSink); i >= 0 {
+		old, oldDedup = l.sinks[i].sink, l.sinks[i].dedup
+		if ss := syslogSinkOf(old, cfg); ss != nil {
+			old := ss.swap(w, cfg)
+			l.sinksMu.Unlock()
+			return old.Close()
+		}
+		// Take over a spool in the same directory
+		if ss := innerSyslogSink(old); ss.spool != nil && ss.spool.dir == cfg.spoolDir {
+			sp = ss.takeSpool()
+		}
+	}
+	if sp == nil && cfg.spoolDir != "" {
+		if sp, err = openSpool(cfg.spoolDir, cfg.spoolMaxSize, cfg.spoolMaxAge); err != nil {
+			l.sinksMu.Unlock()
+			_ = w.Close()
+			return err
+		}
+	}
+	if old != nil {
+		_, _ = l.removeSink(SyslogSink)
+	}
+
+	var s Sink = newSyslogSink(w, cfg, sp)
+	if cfg.async {
+		s = NewAsyncSink(s, cfg.queueSize, cfg.overflow)
+	}
+	_ = l.addSink(SyslogSink, s, l.getSyslogLevel())
+	l.sinks[len(l.sinks)-1].dedup = oldDedup.to(s)
+	l.sinksMu.Unlock()
+
+	// Close a connection with different delivery options only once it has
+	// been replaced, sending any logs queued by it.
+	if old != nil {
+		oldDedup.stop()
+		return closeSyslogSink(old)
+	}
+	return nil
+}
+
+// SyslogDropped returns the number of logs dropped because the queue of an
+// asynchronous syslog connection was full, because a synchronous connection
+// was being re-dialed or due to the limits of the spool.
+func (l *Logger) SyslogDropped() uint64 {
+	for _, entry := range l.getSinks() {
+		if entry.name != SyslogSink {
+			continue
+		}
+		var dropped uint64
+		if a, ok := entry.sink.(*AsyncSink); ok {
+			dropped = a.Dropped()
+		}
+		ss := innerSyslogSink(entry.sink)
+		dropped += atomic.LoadUint64(&ss.dropped)
+
+		ss.mu.Lock()
+		if ss.spool != nil {
+			dropped += ss.spool.droppedEntries()
+		}
+		ss.mu.Unlock()
+		return dropped
+	}
+	return 0
+}
+
+// SetSyslogLevel alters the verbosity level that logs will be sent to syslog
+// at and below, independent of the level of local output. It takes values
+// syslog.LOG_EMERG...syslog.LOG_DEBUG. The default is syslog.LOG_DEBUG, so all
+// logs are sent. If the priority includes a facility it will be ignored.
+func (l *Logger) SetSyslogLevel(p syslog.Priority) {
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	l.setSyslogLevel(p)
+}
+
+// Only call with a write lock on the sinks mutex
+func (l *Logger) setSyslogLevel(p syslog.Priority) {
+	if p < syslog.LOG_EMERG {
+		p = syslog.LOG_EMERG
+	}
+	l.syslogLevel = p & severityMask
+	l.syslogLevelSet = true
+	_ = l.setSinkLevel(SyslogSink, l.syslogLevel)
+}
+
+// GetSyslogLevel returns the verbosity level that logs will be sent to syslog
+// at and below.
+func (l *Logger) GetSyslogLevel() syslog.Priority {
+	l.sinksMu.RLock()
+	defer l.sinksMu.RUnlock()
+	return l.getSyslogLevel()
+}
+
+// Only call with a read lock on the sinks mutex
+func (l *Logger) getSyslogLevel() syslog.Priority {
+	if !l.syslogLevelSet {
+		return syslog.LOG_DEBUG
+	}
+	return l.syslogLevel
+}
+
+// DisconnectSyslog closes the connection to syslog. Logs queued by the
+// SyslogAsync option are sent first, unless the connection is being re-dialed
+// in which case they are dropped.
+func (l *Logger) DisconnectSyslog() error {
+	l.sinksOnce.Do(l.initSinks)
+
+	l.sinksMu.Lock()
+	entry, err := l.removeSink(SyslogSink)
+	l.sinksMu.Unlock()
+	if err != nil {
+		return nil
+	}
+	entry.dedup.stop()
+	return closeSyslogSink(entry.sink)
+}
+
+// syslogSinkOf returns the syslogSink of s, if s was created with the same
+// delivery options as cfg.
+func syslogSinkOf(s Sink, cfg syslogConfig) *syslogSink {
+	if a, ok := s.(*AsyncSink); ok {
+		size := cfg.queueSize
+		if size < 1 {
+			size = 1
+		}
+		if !cfg.async || a.policy != cfg.overflow || a.size != size {
+			return nil
+		}
+	} else if cfg.async {
+		return nil
+	}
+	ss := innerSyslogSink(s)
+	if ss.spool == nil && cfg.spoolDir != "" || ss.spool != nil && ss.spool.dir != cfg.spoolDir {
+		return nil
+	}
+	return ss
+}
+
+// innerSyslogSink returns the syslogSink of a sink created by connect.
+func innerSyslogSink(s Sink) *syslogSink {
+	if a, ok := s.(*AsyncSink); ok {
+		s = a.sink
+	}
+	return s.(*syslogSink)
+}
+
+// syslogSink writes records to a syslog connection, re-dialing it in the
+// background when it breaks.
+type syslogSink struct {
+	dropped uint64 // accessed atomically, first for alignment
+
+	mu         sync.Mutex
+	w          *slog.Writer
+	sdID       string // empty unless fields are sent as structured data
+	wait       bool   // block writes while disconnected, instead of dropping
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	up         chan struct{} // closed when connected or closed
+	connected  bool
+	quit       chan struct{} // closed when closed
+	reported   time.Time     // when dropped logs were last reported
+
+	spool     *spool // nil unless records are spooled while disconnected
+	spooling  bool   // records must be spooled until the spool is sent
+	replaying bool   // the spool is being sent
+
+	tlsFiles *tlsFiles // nil unless the TLS config is loaded from files
+	watching bool      // watchTLSFiles has been started
+}
+
+func newSyslogSink(w *slog.Writer, cfg syslogConfig, sp *spool) *syslogSink {
+	s := &syslogSink{
+		up:        make(chan struct{}),
+		connected: true,
+		quit:      make(chan struct{}),
+		spool:     sp,
+	}
+	close(s.up)
+	s.configure(w, cfg)
+	if sp != nil && !sp.empty() {
+		// Send records spooled by a previous process
+		s.spooling = true
+		s.startReplay()
+	}
+	return s
+}
+
+// Only call with a lock on the mutex, or before the sink is shared
+func (s *syslogSink) configure(w *slog.Writer, cfg syslogConfig) {
+	s.w = w
+	s.sdID = cfg.sdID
+	s.wait = cfg.async
+	s.minBackoff = cfg.minBackoff
+	s.maxBackoff = cfg.maxBackoff
+	s.tlsFiles = cfg.tlsFiles
+	if s.tlsFiles != nil && !s.watching {
+		s.watching = true
+		go s.watchTLSFiles()
+	}
+}
+
+// WriteRecord implements Sink. While disconnected, the record is spooled,
+// dropped or, if the sink is asynchronous, the write blocks until it is
+// re-dialed. Dropped records are reported by an error at most every
+// dropReportInterval.
+func (s *syslogSink) WriteRecord(r *Record) error {
+	for {
+		s.mu.Lock()
+		select {
+		case <-s.quit:
+			s.mu.Unlock()
+			return ErrSinkClosed
+		default:
+		}
+		e := syslogEntry(s.sdID, r)
+		if s.spool != nil && (s.spooling || !s.connected) {
+			// Append under the lock, so that the spool is not found empty
+			// and sending it stopped before this record is sent
+			s.spooling = true
+			err := s.spool.append(e)
+			s.mu.Unlock()
+			return err
+		}
+		w, wait, up, connected := s.w, s.wait, s.up, s.connected
+		if !connected && !wait {
+			err := s.dropError(atomic.AddUint64(&s.dropped, 1))
+			s.mu.Unlock()
+			return err
+		}
+		s.mu.Unlock()
+
+		if !connected {
+			<-up
+			continue
+		}
+
+		err := writeSyslogEntry(w, e)
+		if err == nil {
+			return nil
+		}
+		s.disconnect(w)
+		if !wait && s.spool == nil {
+			return err
+		}
+	}
+}
+
+// dropError returns an error reporting the number of logs dropped while
+// re-dialing, unless one was returned within dropReportInterval. Only call
+// with a lock on the mutex.
+func (s *syslogSink) dropError(dropped uint64) error {
+	now := time.Now()
+	if now.Sub(s.reported) < dropReportInterval {
+		return nil
+	}
+	s.reported = now
+	return fmt.Errorf("dropping logs while re-dialing (%d dropped so far)", dropped)
+}
+
+// disconnect starts re-dialing the connection of w, unless it has been
+// replaced or is already being re-dialed.
+func (s *syslogSink) disconnect(w *slog.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w != w || !s.connected {
+		return
+	}
+	s.connected = false
+	s.up = make(chan struct{})
+	go s.redial(w, s.minBackoff, s.maxBackoff)
+}
+
+// redial re-dials the connection of w with exponential backoff until it
+// succeeds, w is replaced or the sink is closed.
+func (s *syslogSink) redial(w *slog.Writer, min, max time.Duration) {
+	for delay := min; ; {
+		// Wait between delay/2 and delay
+		jittered := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		t := time.NewTimer(jittered)
+		select {
+		case <-s.quit:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		replaced := s.w != w
+		s.mu.Unlock()
+		if replaced {
+			return
+		}
+
+		if err := w.Reconnect(); err == nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			select {
+			case <-s.quit:
+				_ = w.Close() // closed while dialing
+				return
+			default:
+			}
+			if s.w != w {
+				_ = w.Close() // replaced while dialing
+				return
+			}
+			s.connected = true
+			close(s.up)
+			s.startReplay()
+			return
+		}
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
+
+// Only call with a lock on the mutex
+func (s *syslogSink) startReplay() {
+	if s.spooling && !s.replaying {
+		s.replaying = true
+		go s.replay(s.spool)
+	}
+}
+
+// replay sends the spool sp in order, until it is empty, the connection
+// breaks or sp is taken by another sink.
+func (s *syslogSink) replay(sp *spool) {
+	for {
+		seq, entries := sp.oldest()
+		for _, e := range entries {
+			s.mu.Lock()
+			w, ok := s.w, s.connected && s.spool == sp
+			select {
+			case <-s.quit:
+				ok = false
+			default:
+			}
+			if !ok {
+				sp.done(seq, e.line, false)
+				s.replaying = false
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Unlock()
+
+			if err := writeSyslogEntry(w, e); err != nil {
+				s.mu.Lock()
+				sp.done(seq, e.line, false)
+				s.replaying = false
+				s.mu.Unlock()
+				s.disconnect(w)
+				return
+			}
+		}
+		sp.done(seq, 0, true)
+
+		s.mu.Lock()
+		if s.spool != sp || sp.empty() {
+			s.spooling = false
+			s.replaying = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// swap replaces the connection of the sink with w, returning the old one to
+// be closed.
+func (s *syslogSink) swap(w *slog.Writer, cfg syslogConfig) *slog.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.w
+	s.configure(w, cfg)
+	if !s.connected {
+		s.connected = true
+		close(s.up)
+		s.startReplay()
+	}
+	return old
+}
+
+// takeSpool removes the spool from the sink, so that it can be used by a
+// replacement. Records are no longer spooled while disconnected.
+func (s *syslogSink) takeSpool() *spool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.spool
+	s.spool = nil
+	s.spooling = false
+	return sp
+}
+
+// stopWaiting drops records while disconnected instead of blocking, so that
+// an asynchronous sink can be closed without waiting for a re-dial.
+func (s *syslogSink) stopWaiting() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wait = false
+	if !s.connected {
+		close(s.up)
+		s.up = make(chan struct{})
+	}
+}
+
+// closeSyslogSink closes a sink created by connect, sending any queued logs
+// unless disconnected.
+func closeSyslogSink(s Sink) error {
+	if a, ok := s.(*AsyncSink); ok {
+		a.sink.(*syslogSink).stopWaiting()
+	}
+	return s.(io.Closer).Close()
+}
+
+// Reload implements Reloader by re-reading the TLS files of the connection,
+// if any, to be used when it is next dialed.
+func (s *syslogSink) Reload() error {
+	s.mu.Lock()
+	files := s.tlsFiles
+	s.mu.Unlock()
+	if files == nil {
+		return nil
+	}
+
+	conf, err := files.load()
+	if err != nil {
+		return fmt.Errorf("error reloading syslog TLS files: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tlsFiles == files {
+		s.w.SetTLSConfig(conf)
+	}
+	return nil
+}
+
+// watchTLSFiles reloads the TLS files of the connection when they change,
+// until the sink is closed.
+func (s *syslogSink) watchTLSFiles() {
+	t := time.NewTicker(tlsPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		files := s.tlsFiles
+		s.mu.Unlock()
+		if files != nil && files.changed() {
+			if err := s.Reload(); err != nil {
+				printError("%v", err)
+			}
+		}
+	}
+}
+
+// Close implements io.Closer. Spooled records are kept to be sent by the next
+// sink using the spool directory.
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.quit:
+		return ErrSinkClosed
+	default:
+	}
+	close(s.quit)
+	if !s.connected {
+		close(s.up)
+	}
+	err := s.w.Close()
+	if s.spool != nil {
+		if serr := s.spool.close(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Helper func to render r as a syslog message, with fields as structured data
+// with the SD-ID sdID or, if empty, as a prefix of the message.
+func syslogEntry(sdID string, r *Record) spoolEntry {
+	e := spoolEntry{Time: r.Time, Priority: r.Priority, Message: r.Message}
+	if sdID == "" {
+		e.Message = string(appendFieldTags(nil, r.Fields)) + r.Message
+	} else if len(r.Fields) > 0 {
+		e.SD = slog.SDElement{ID: sdID, Params: fieldParams(r.Fields)}.String()
+	}
+	return e
+}
+
+// Helper func to write e to syslog with its original timestamp.
+func writeSyslogEntry(w *slog.Writer, e spoolEntry) error {
+	return w.WriteAt(e.Time, e.Priority, e.SD, e.Message)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package syslog
+
+import (
+	"errors"
+	"strings"
+)
+
+// maxSDNameLen is the maximum length of an SD-ID or PARAM-NAME.
+const maxSDNameLen = 32
+
+// SDParam is a single PARAM-NAME="PARAM-VALUE" pair of an SDElement.
+type SDParam struct {
+	Name  string
+	Value string
+}
+
+// SDElement is an RFC 5424 structured data element.
+type SDElement struct {
+	ID     string
+	Params []SDParam
+}
+
+// ValidSDID returns an error if id cannot be used as an SD-ID.
+func ValidSDID(id string) error {
+	if id == "" {
+		return errors.New("log/syslog: empty SD-ID")
+	}
+	if len(id) > maxSDNameLen {
+		return errors.New("log/syslog: SD-ID longer than 32 characters")
+	}
+	if strings.IndexFunc(id, func(r rune) bool { return !isSDNameRune(r) }) >= 0 {
+		return errors.New("log/syslog: invalid character in SD-ID")
+	}
+	return nil
+}
+
+// String renders the element as [ID NAME="VALUE" ...]. Invalid characters in
+// parameter names are replaced by underscores and '"', '\' and ']' in values
+// are escaped.
+func (e SDElement) String() string {
+	var b strings.Builder
+	b.WriteByte('[')
+	b.WriteString(e.ID)
+	for _, param := range e.Params {
+		b.WriteByte(' ')
+		b.WriteString(sdName(param.Name))
+		b.WriteString(`="`)
+		for i := 0; i < len(param.Value); i++ {
+			switch c := param.Value[i]; c {
+			case '"', '\\', ']':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// sdName converts s into a valid PARAM-NAME.
+func sdName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if !isSDNameRune(r) {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > maxSDNameLen {
+		s = s[:maxSDNameLen]
+	}
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// isSDNameRune reports whether r is allowed in an SD-NAME: printable US-ASCII
+// except '=', ' ', ']' and '"'.
+func isSDNameRune(r rune) bool {
+	return r > 32 && r < 127 && r != '=' && r != ']' && r != '"'
+}
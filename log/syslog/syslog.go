@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/syslog"
 	"net"
@@ -22,6 +23,46 @@ import (
 const severityMask = 0x07
 const facilityMask = 0xf8
 
+// ErrNotConnected is returned by writes when a Writer is not connected and
+// retries are disabled with SetRetry.
+var ErrNotConnected = errors.New("log/syslog: not connected")
+
+// Format selects the header layout of messages sent by a Writer.
+type Format int
+
+const (
+	// RFC3164 is the legacy BSD syslog format:
+	// <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
+	RFC3164 Format = iota
+	// RFC5424 is the IETF syslog protocol format:
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	RFC5424
+)
+
+// Framing selects how messages are delimited on stream transports, such as
+// TCP and TLS, see RFC 6587. It does not apply to datagram transports, such as
+// UDP, or the local syslog service.
+type Framing int
+
+const (
+	// NonTransparent terminates each message with a newline, so messages
+	// containing newlines are split by the server.
+	NonTransparent Framing = iota
+	// OctetCounting prefixes each message with its length in bytes and a
+	// space, so messages may contain newlines.
+	OctetCounting
+)
+
+// rfc5424Time is RFC3339 restricted to the maximum six digits of fractional
+// seconds allowed by RFC 5424.
+const rfc5424Time = "2006-01-02T15:04:05.000000Z07:00"
+
+// Maximum field lengths from RFC 5424 section 6.
+const (
+	maxHostnameLen = 255
+	maxAppNameLen  = 48
+)
+
 // A Writer is a connection to a syslog server.
 type Writer struct {
 	priority syslog.Priority
@@ -31,8 +72,11 @@ type Writer struct {
 	raddr    string
 	conf     *tls.Config
 
-	mu   sync.Mutex // guards conn
-	conn serverConn
+	mu      sync.Mutex // guards conn, format, framing and noRetry
+	conn    serverConn
+	format  Format
+	framing Framing
+	noRetry bool
 }
 
 // This interface and the separate syslog_unix.go file exist for
@@ -42,13 +86,14 @@ type Writer struct {
 // return a type that satisfies this interface and simply calls the C
 // library syslog function.
 type serverConn interface {
-	writeString(p syslog.Priority, hostname, tag, s, nl string) error
+	writeString(f Format, fr Framing, t time.Time, p syslog.Priority, hostname, tag, sd, s, nl string) error
 	close() error
 }
 
 type netConn struct {
-	local bool
-	conn  net.Conn
+	local  bool
+	stream bool
+	conn   net.Conn
 }
 
 // New establishes a new connection to the system log daemon. Each
@@ -106,37 +151,115 @@ func dial(network, raddr string, priority syslog.Priority, tag string, conf *tls
 
 // connect makes a connection to the syslog server.
 // It must be called with w.mu held.
-func (w *Writer) connect(conf *tls.Config) (err error) {
+func (w *Writer) connect(conf *tls.Config) error {
 	if w.conn != nil {
 		// ignore err from close, it makes sense to continue anyway
 		w.conn.close()
 		w.conn = nil
 	}
 
+	conn, hostname, err := w.dialConn(conf)
+	if err != nil {
+		return err
+	}
+	w.setConn(conn, hostname)
+	return nil
+}
+
+// dialConn dials the syslog server, returning the connection and the hostname
+// to use if none is set. It does not need w.mu held, as it only reads fields
+// set by dial.
+func (w *Writer) dialConn(conf *tls.Config) (serverConn, string, error) {
 	if w.network == "" {
-		w.conn, err = unixSyslog()
-		if w.hostname == "" {
-			w.hostname = "localhost"
-		}
-	} else {
-		var c net.Conn
-		c, err = net.Dial(w.network, w.raddr)
-		if err == nil {
-			if conf != nil {
-				c = tls.Client(c, conf)
-			}
-			w.conn = &netConn{conn: c}
-			if w.hostname == "" {
-				w.hostname = c.LocalAddr().String()
-			}
-		}
+		conn, err := unixSyslog()
+		return conn, "localhost", err
+	}
+
+	c, err := net.Dial(w.network, w.raddr)
+	if err != nil {
+		return nil, "", err
+	}
+	if conf != nil {
+		c = tls.Client(c, conf)
+	}
+	return &netConn{conn: c, stream: isStream(w.network)}, c.LocalAddr().String(), nil
+}
+
+// setConn replaces the connection, closing the previous one if any.
+// It must be called with w.mu held.
+func (w *Writer) setConn(conn serverConn, hostname string) {
+	if w.conn != nil {
+		// ignore err from close, it makes sense to continue anyway
+		w.conn.close()
+	}
+	w.conn = conn
+	if w.hostname == "" {
+		w.hostname = hostname
+	}
+}
+
+// isStream reports whether network is a stream rather than datagram network.
+func isStream(network string) bool {
+	return !strings.HasPrefix(network, "udp") && network != "unixgram" && !strings.HasPrefix(network, "ip")
+}
+
+// SetFraming changes how all subsequent messages are delimited on stream
+// transports. The default is NonTransparent.
+func (w *Writer) SetFraming(fr Framing) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.framing = fr
+}
+
+// SetFormat changes the header layout of all subsequent messages. The default
+// is RFC3164.
+func (w *Writer) SetFormat(f Format) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.format = f
+}
+
+// SetTLSConfig changes the TLS config used when the connection is next
+// dialed, e.g. by Reconnect. It has no effect unless the Writer was created
+// by DialTLS with a non-nil config.
+func (w *Writer) SetTLSConfig(conf *tls.Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conf != nil && conf != nil {
+		w.conf = conf
 	}
-	return
+}
+
+// SetRetry controls whether a failed write is retried after re-dialing the
+// connection, which blocks the write until the dial completes. The default is
+// true. When false, a failed write closes the connection and writes fail with
+// ErrNotConnected until Reconnect succeeds.
+func (w *Writer) SetRetry(retry bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.noRetry = !retry
+}
+
+// Reconnect dials the syslog daemon again and, if that succeeds, replaces the
+// connection, closing the previous one. Writes are not blocked while dialing.
+func (w *Writer) Reconnect() error {
+	w.mu.Lock()
+	conf := w.conf
+	w.mu.Unlock()
+
+	conn, hostname, err := w.dialConn(conf)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.setConn(conn, hostname)
+	return nil
 }
 
 // Write sends a log message to the syslog daemon.
 func (w *Writer) Write(b []byte) (int, error) {
-	return w.writeAndRetry(w.priority, string(b))
+	return w.writeAndRetry(time.Now(), w.priority, "", string(b))
 }
 
 // Close closes a connection to the syslog daemon.
@@ -155,86 +278,113 @@ func (w *Writer) Close() error {
 // Emerg logs a message with severity LOG_EMERG, ignoring the severity
 // passed to New.
 func (w *Writer) Emerg(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_EMERG, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_EMERG, "", m)
 	return err
 }
 
 // Alert logs a message with severity LOG_ALERT, ignoring the severity
 // passed to New.
 func (w *Writer) Alert(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_ALERT, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_ALERT, "", m)
 	return err
 }
 
 // Crit logs a message with severity LOG_CRIT, ignoring the severity
 // passed to New.
 func (w *Writer) Crit(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_CRIT, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_CRIT, "", m)
 	return err
 }
 
 // Err logs a message with severity LOG_ERR, ignoring the severity
 // passed to New.
 func (w *Writer) Err(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_ERR, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_ERR, "", m)
 	return err
 }
 
 // Warning logs a message with severity LOG_WARNING, ignoring the
 // severity passed to New.
 func (w *Writer) Warning(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_WARNING, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_WARNING, "", m)
 	return err
 }
 
 // Notice logs a message with severity LOG_NOTICE, ignoring the
 // severity passed to New.
 func (w *Writer) Notice(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_NOTICE, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_NOTICE, "", m)
 	return err
 }
 
 // Info logs a message with severity LOG_INFO, ignoring the severity
 // passed to New.
 func (w *Writer) Info(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_INFO, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_INFO, "", m)
 	return err
 }
 
 // Debug logs a message with severity LOG_DEBUG, ignoring the severity
 // passed to New.
 func (w *Writer) Debug(m string) error {
-	_, err := w.writeAndRetry(syslog.LOG_DEBUG, m)
+	_, err := w.writeAndRetry(time.Now(), syslog.LOG_DEBUG, "", m)
+	return err
+}
+
+// WriteStructured logs a message with the severity of p, ignoring the
+// facility of p and the severity passed to New, and the structured data sd.
+// The structured data is only sent in the RFC5424 format and must either be
+// empty or one or more valid SD-ELEMENTs, such as returned by
+// SDElement.String.
+func (w *Writer) WriteStructured(p syslog.Priority, sd, m string) error {
+	_, err := w.writeAndRetry(time.Now(), p, sd, m)
 	return err
 }
 
-func (w *Writer) writeAndRetry(p syslog.Priority, s string) (int, error) {
+// WriteAt is like WriteStructured, but with the timestamp t instead of the
+// current time, e.g. for messages that could not be sent when logged.
+func (w *Writer) WriteAt(t time.Time, p syslog.Priority, sd, m string) error {
+	_, err := w.writeAndRetry(t, p, sd, m)
+	return err
+}
+
+func (w *Writer) writeAndRetry(t time.Time, p syslog.Priority, sd, s string) (int, error) {
 	pr := (w.priority & facilityMask) | (p & severityMask)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	if w.conn != nil {
-		if n, err := w.write(pr, s); err == nil {
+		n, err := w.write(t, pr, sd, s)
+		if err == nil {
 			return n, err
 		}
+		if w.noRetry {
+			// ignore err from close, the write error is more relevant
+			w.conn.close()
+			w.conn = nil
+			return 0, err
+		}
+	}
+	if w.noRetry {
+		return 0, ErrNotConnected
 	}
 	if err := w.connect(w.conf); err != nil {
 		return 0, err
 	}
-	return w.write(pr, s)
+	return w.write(t, pr, sd, s)
 }
 
-// write generates and writes a syslog formatted string. The
-// format is as follows: <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
-func (w *Writer) write(p syslog.Priority, msg string) (int, error) {
+// write generates and writes a syslog formatted string. The format depends
+// on the Format of the Writer, see RFC3164 and RFC5424.
+func (w *Writer) write(t time.Time, p syslog.Priority, sd, msg string) (int, error) {
 	// ensure it ends in a \n
 	nl := ""
 	if !strings.HasSuffix(msg, "\n") {
 		nl = "\n"
 	}
 
-	err := w.conn.writeString(p, w.hostname, w.tag, msg, nl)
+	err := w.conn.writeString(w.format, w.framing, t, p, w.hostname, w.tag, sd, msg, nl)
 	if err != nil {
 		return 0, err
 	}
@@ -244,24 +394,60 @@ func (w *Writer) write(p syslog.Priority, msg string) (int, error) {
 	return len(msg), nil
 }
 
-func (n *netConn) writeString(p syslog.Priority, hostname, tag, msg, nl string) error {
-	if n.local {
+func (n *netConn) writeString(f Format, fr Framing, t time.Time, p syslog.Priority, hostname, tag, sd, msg, nl string) error {
+	var s string
+	switch {
+	case f == RFC5424:
+		timestamp := t.UTC().Format(rfc5424Time)
+		if sd == "" {
+			sd = "-"
+		}
+		s = fmt.Sprintf("<%d>1 %s %s %s %d - %s %s",
+			p, timestamp, headerField(hostname, maxHostnameLen),
+			headerField(tag, maxAppNameLen), os.Getpid(), sd, msg)
+	case n.local:
 		// Compared to the network form below, the changes are:
 		//	1. Use time.Stamp instead of time.RFC3339.
 		//	2. Drop the hostname field from the Fprintf.
-		timestamp := time.Now().Format(time.Stamp)
-		_, err := fmt.Fprintf(n.conn, "<%d>%s %s[%d]: %s%s",
+		timestamp := t.Format(time.Stamp)
+		s = fmt.Sprintf("<%d>%s %s[%d]: %s",
 			p, timestamp,
-			tag, os.Getpid(), msg, nl)
+			tag, os.Getpid(), msg)
+	default:
+		timestamp := t.Format(time.RFC3339)
+		s = fmt.Sprintf("<%d>%s %s %s[%d]: %s",
+			p, timestamp, hostname,
+			tag, os.Getpid(), msg)
+	}
+
+	if fr == OctetCounting && n.stream && !n.local {
+		// The length delimits the message, so no newline is needed
+		s = strings.TrimSuffix(s, "\n")
+		_, err := fmt.Fprintf(n.conn, "%d %s", len(s), s)
 		return err
 	}
-	timestamp := time.Now().Format(time.RFC3339)
-	_, err := fmt.Fprintf(n.conn, "<%d>%s %s %s[%d]: %s%s",
-		p, timestamp, hostname,
-		tag, os.Getpid(), msg, nl)
+	_, err := io.WriteString(n.conn, s+nl)
 	return err
 }
 
+// headerField converts s into an RFC 5424 header field: printable US-ASCII
+// without spaces, at most max characters long and "-" when empty.
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func (n *netConn) close() error {
 	return n.conn.Close()
 }
@@ -6,13 +6,24 @@ package log_test
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/open-ness/common/log"
+	slog "github.com/open-ness/common/log/syslog"
 )
 
 func TestLoggerConnectSyslogLocal(t *testing.T) { // nolint: gocyclo
@@ -95,3 +106,388 @@ WaitForWrite:
 	_ = cmd.Process.Kill()
 	_ = cmd.Wait()
 }
+
+func TestLoggerConnectSyslogRFC5424(t *testing.T) {
+	conn := listenSyslog(t)
+	defer conn.Close()
+
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslog(conn.LocalAddr().String(), log.SyslogFormat(slog.RFC5424)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	logger.Warning("hello")
+
+	matcher := regexp.MustCompile(
+		`^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z \S+ \S+ \d+ - - hello\n$`)
+	if msg := readSyslog(t, conn); !matcher.MatchString(msg) {
+		t.Errorf("expected %q to match regexp %q", msg, matcher)
+	}
+}
+
+func TestLoggerConnectSyslogStructuredData(t *testing.T) {
+	conn := listenSyslog(t)
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	if err := logger.ConnectSyslog(conn.LocalAddr().String(), log.SyslogStructuredData("fields@32473")); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	logger.WithField("path", `C:\"a]"`).Warning("hello")
+
+	// Expect local output to be unchanged
+	if expect := `: [path=C:\"a]"] hello` + "\n"; !strings.HasSuffix(buf.String(), expect) {
+		t.Errorf("expected %q to end with %q", buf.String(), expect)
+	}
+
+	// Expect fields in structured data of syslog message
+	msg := readSyslog(t, conn)
+	if expect := ` - [fields@32473 path="C:\\\"a\]\""] hello` + "\n"; !strings.HasSuffix(msg, expect) {
+		t.Errorf("expected %q to end with %q", msg, expect)
+	}
+}
+
+func TestLoggerConnectSyslogInvalidSDID(t *testing.T) {
+	logger := new(log.Logger)
+	if err := logger.ConnectSyslog("127.0.0.1:514", log.SyslogStructuredData("a b")); err == nil {
+		_ = logger.DisconnectSyslog()
+		t.Errorf("expected error connecting with invalid SD-ID")
+	}
+}
+
+func TestLoggerSyslogLevel(t *testing.T) {
+	conn := listenSyslog(t)
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetLevel(syslog.LOG_DEBUG)
+	if err := logger.ConnectSyslog(conn.LocalAddr().String(), log.SyslogLevel(syslog.LOG_WARNING)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	// Expect only WARNING to be sent to syslog, but both to be written locally
+	logger.Info("info")
+	logger.Warning("warning")
+	if msg := readSyslog(t, conn); !strings.HasSuffix(msg, "warning\n") {
+		t.Errorf("expected %q to end with 'warning\\n'", msg)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("expected 2 lines of local output, got %d", lines)
+	}
+
+	// Expect changed level to be applied
+	logger.SetSyslogLevel(syslog.LOG_INFO)
+	if lvl := logger.GetSyslogLevel(); lvl != syslog.LOG_INFO {
+		t.Errorf("expected syslog level %d, got %d", syslog.LOG_INFO, lvl)
+	}
+	logger.Debug("debug")
+	logger.Info("info")
+	if msg := readSyslog(t, conn); !strings.HasSuffix(msg, "info\n") {
+		t.Errorf("expected %q to end with 'info\\n'", msg)
+	}
+}
+
+// listenSyslog starts a local UDP server to connect a Logger to.
+func listenSyslog(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening for udp: %v", err)
+	}
+	return conn
+}
+
+// readSyslog reads the next message received by a server from listenSyslog.
+func readSyslog(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("error reading from syslog listener: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestLoggerConnectSyslogReplace(t *testing.T) {
+	conn1, conn2 := listenSyslog(t), listenSyslog(t)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslog(conn1.LocalAddr().String(), log.SyslogAsync(10, log.OverflowBlock)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+	logger.Info("first")
+	if msg := readSyslog(t, conn1); !strings.HasSuffix(msg, "first\n") {
+		t.Errorf("expected %q to end with 'first\\n'", msg)
+	}
+
+	// Expect connecting again to replace the connection
+	if err := logger.ConnectSyslog(conn2.LocalAddr().String(), log.SyslogAsync(10, log.OverflowBlock)); err != nil {
+		t.Fatalf("error replacing syslog connection: %v", err)
+	}
+	logger.Info("second")
+	if msg := readSyslog(t, conn2); !strings.HasSuffix(msg, "second\n") {
+		t.Errorf("expected %q to end with 'second\\n'", msg)
+	}
+
+	// Expect a failed dial to keep the existing connection
+	if err := logger.ConnectSyslog("256.0.0.1:514"); err == nil {
+		t.Fatal("expected error connecting to invalid address")
+	}
+	logger.Info("third")
+	if msg := readSyslog(t, conn2); !strings.HasSuffix(msg, "third\n") {
+		t.Errorf("expected %q to end with 'third\\n'", msg)
+	}
+	if names := logger.SinkNames(); len(names) != 2 {
+		t.Errorf("expected output and syslog sinks, got %v", names)
+	}
+}
+
+func TestLoggerSyslogRedial(t *testing.T) {
+	serverConf, clientConf := tlsConfigs(t)
+	ln, lines, accepted := listenSyslogTLS(t, "127.0.0.1:0", serverConf)
+	defer ln.Close()
+
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslogTLS(ln.Addr().String(), clientConf,
+		log.SyslogAsync(100, log.OverflowDropNewest),
+		log.SyslogBackoff(time.Millisecond, 10*time.Millisecond)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	logger.Info("before")
+	if line := <-lines; !strings.HasSuffix(line, "before\n") {
+		t.Errorf("expected %q to end with 'before\\n'", line)
+	}
+
+	// Break the connection and expect logs to be sent once it is re-dialed
+	(<-accepted).Close()
+	timeout := time.After(5 * time.Second)
+	for {
+		logger.Info("after")
+		select {
+		case <-accepted:
+			for line := range lines {
+				if strings.HasSuffix(line, "after\n") {
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("expected syslog connection to be re-dialed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestLoggerSyslogRedialDropped(t *testing.T) {
+	serverConf, clientConf := tlsConfigs(t)
+	ln, _, accepted := listenSyslogTLS(t, "127.0.0.1:0", serverConf)
+	defer ln.Close()
+
+	var buf syncBuffer
+	logger := new(log.Logger)
+	logger.SetOutput(&buf)
+	logger.SetSyslogLevel(syslog.LOG_INFO)
+	logger.SetLevel(syslog.LOG_NOTICE)
+	if err := logger.ConnectSyslogTLS(ln.Addr().String(), clientConf,
+		log.SyslogBackoff(time.Hour, time.Hour)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	// Break the connection and expect logs dropped while re-dialing to be
+	// reported once
+	(<-accepted).Close()
+	for start := time.Now(); !strings.Contains(buf.String(), "dropping logs"); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("expected dropped logs to be reported in output %q", buf.String())
+		}
+		logger.Info("dropped")
+	}
+	for i := 0; i < 10; i++ {
+		logger.Info("dropped")
+	}
+	if n := strings.Count(buf.String(), "dropping logs"); n != 1 {
+		t.Errorf("expected dropped logs reported once, got %d in output %q", n, buf.String())
+	}
+	if logger.SyslogDropped() == 0 {
+		t.Error("expected dropped logs to be counted")
+	}
+}
+
+func TestLoggerSyslogSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("error creating spool dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverConf, clientConf := tlsConfigs(t)
+	ln, lines, accepted := listenSyslogTLS(t, "127.0.0.1:0", serverConf)
+	addr := ln.Addr().String()
+
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslogTLS(addr, clientConf,
+		log.SyslogFormat(slog.RFC5424),
+		log.SyslogSpool(dir, 0, time.Hour),
+		log.SyslogBackoff(time.Millisecond, 10*time.Millisecond)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	// Stop the server and log until the connection is found broken
+	ln.Close()
+	(<-accepted).Close()
+	for start := time.Now(); !spooled(t, dir); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("expected logs to be spooled")
+		}
+		logger.Info("probe")
+	}
+	logger.Info("first")
+	logger.Info("second")
+	logged := time.Now()
+	time.Sleep(20 * time.Millisecond)
+
+	// Restart the server and expect the logs to be sent in order with their
+	// original timestamps
+	ln, lines, _ = listenSyslogTLS(t, addr, serverConf)
+	defer ln.Close()
+	var first string
+	for line := range lines {
+		if strings.HasSuffix(line, " first\n") {
+			first = line
+		}
+		if strings.HasSuffix(line, " second\n") {
+			break
+		}
+	}
+	if first == "" {
+		t.Fatal("expected first log before second")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, strings.Fields(first)[1])
+	if err != nil {
+		t.Fatalf("error parsing timestamp of %q: %v", first, err)
+	}
+	if ts.After(logged) {
+		t.Errorf("expected original timestamp before %v, got %v", logged, ts)
+	}
+
+	// Expect sent logs to be removed from the spool
+	for start := time.Now(); spooled(t, dir); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("expected spool to be emptied")
+		}
+	}
+}
+
+// spooled reports whether dir contains any spool segments.
+func spooled(t *testing.T, dir string) bool {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.spool"))
+	if err != nil {
+		t.Fatalf("error listing spool dir: %v", err)
+	}
+	return len(matches) > 0
+}
+
+// listenSyslogTLS starts a local TLS server to connect a Logger to, returning
+// the lines received and connections accepted.
+func listenSyslogTLS(t *testing.T, addr string, conf *tls.Config) (net.Listener, <-chan string, <-chan net.Conn) {
+	ln, err := tls.Listen("tcp", addr, conf)
+	if err != nil {
+		t.Fatalf("error listening for tls: %v", err)
+	}
+	lines := make(chan string, 100)
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+			go func() {
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	return ln, lines, accepted
+}
+
+// tlsConfigs returns configs for a server with a self-signed certificate for
+// 127.0.0.1 and a client trusting it.
+func tlsConfigs(t *testing.T) (server, client *tls.Config) {
+	cert, key := newCert(t, "127.0.0.1", nil, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+func TestLoggerSyslogOctetCounting(t *testing.T) {
+	serverConf, clientConf := tlsConfigs(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConf)
+	if err != nil {
+		t.Fatalf("error listening for tls: %v", err)
+	}
+	defer ln.Close()
+
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslogTLS(ln.Addr().String(), clientConf,
+		log.SyslogFraming(slog.OctetCounting)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("error accepting connection: %v", err)
+	}
+	defer c.Close()
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(c)
+
+	// Log while reading, as the TLS handshake completes on the first write
+	go func() {
+		logger.Info("first\nline")
+		logger.Infoln("second")
+	}()
+
+	// Expect each message to be prefixed by its length, keeping newlines
+	for _, expect := range []string{"first\nline", "second"} {
+		var n int
+		if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+			t.Fatalf("error reading message length: %v", err)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatalf("error reading message: %v", err)
+		}
+		if !strings.HasSuffix(string(msg), ": "+expect) {
+			t.Errorf("expected %q to end with %q", msg, ": "+expect)
+		}
+	}
+}
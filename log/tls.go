@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsPollInterval is how often TLS files are checked for changes.
+const tlsPollInterval = 10 * time.Second
+
+// SyslogTLSFiles makes ConnectSyslogTLS authenticate with the client
+// certificate and key in PEM files certFile and keyFile and verify the server
+// with the CA certificates in the PEM file caFile, instead of the system
+// roots. Each may be empty to use the TLS config passed to ConnectSyslogTLS.
+//
+// The files are re-read when they change, or on Reload, and used when the
+// connection is next dialed, so that certificates can be rotated without
+// reconnecting.
+func SyslogTLSFiles(certFile, keyFile, caFile string) SyslogOption {
+	return func(c *syslogConfig) {
+		c.certFile = certFile
+		c.keyFile = keyFile
+		c.caFile = caFile
+	}
+}
+
+// syslogTLSConfig returns a copy of conf to dial addr with, defaulting to the
+// RFC 5425 requirements of TLS 1.2 or later and verification of the server
+// name against addr if conf is nil.
+func syslogTLSConfig(addr string, conf *tls.Config) *tls.Config {
+	if conf == nil {
+		conf = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		conf = conf.Clone()
+	}
+	// Unlike tls.Dial, tls.Client does not take the server name from addr
+	if conf.ServerName == "" && !conf.InsecureSkipVerify {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		conf.ServerName = host
+	}
+	return conf
+}
+
+// tlsFiles loads a TLS config from certificate, key and CA files.
+type tlsFiles struct {
+	base                      *tls.Config
+	certFile, keyFile, caFile string
+
+	mu     sync.Mutex
+	stamps []fileStamp // of the files last loaded
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// load reads the files, returning a copy of the base config using them.
+func (f *tlsFiles) load() (*tls.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stamps := f.stat()
+	conf := f.base.Clone()
+	if f.certFile != "" || f.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if f.caFile != "" {
+		pem, err := ioutil.ReadFile(f.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading CA certificates: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("error loading CA certificates: no certificates found in %s", f.caFile)
+		}
+		conf.RootCAs = pool
+	}
+	f.stamps = stamps
+	return conf, nil
+}
+
+// changed reports whether any of the files changed since last loaded.
+func (f *tlsFiles) changed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stamps := f.stat()
+	for i := range stamps {
+		if stamps[i] != f.stamps[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Only call with a lock on the mutex
+func (f *tlsFiles) stat() []fileStamp {
+	paths := []string{f.certFile, f.keyFile, f.caFile}
+	stamps := make([]fileStamp, len(paths))
+	for i, path := range paths {
+		stamps[i] = statFile(path)
+	}
+	return stamps
+}
+
+// statFile returns the current version of the file at path, or a zero
+// fileStamp if path is empty or the file cannot be read.
+func statFile(path string) fileStamp {
+	if path == "" {
+		return fileStamp{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2019 Intel Corporation
+
+package log_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-ness/common/log"
+)
+
+func TestLoggerSyslogTLSFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write a CA and client certificate signed by it
+	ca, caKey := newCert(t, "ca", nil, nil)
+	var (
+		caFile   = filepath.Join(dir, "ca.pem")
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+	)
+	writePEM(t, caFile, "CERTIFICATE", ca.Raw)
+	writeClientCert := func(name string) {
+		cert, key := newCert(t, name, ca, caKey)
+		writePEM(t, certFile, "CERTIFICATE", cert.Raw)
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("error marshaling key: %v", err)
+		}
+		writePEM(t, keyFile, "EC PRIVATE KEY", der)
+	}
+	writeClientCert("client1")
+
+	// Start a server requiring client certificates signed by the CA
+	server, serverKey := newCert(t, "127.0.0.1", ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	if err != nil {
+		t.Fatalf("error listening for tls: %v", err)
+	}
+	defer ln.Close()
+	clients := make(chan string, 10)
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if err := c.(*tls.Conn).Handshake(); err != nil {
+				c.Close()
+				continue
+			}
+			conns <- c
+			clients <- c.(*tls.Conn).ConnectionState().PeerCertificates[0].Subject.CommonName
+			go func() { _, _ = bufio.NewReader(c).WriteTo(ioutil.Discard) }()
+		}
+	}()
+
+	// Expect to connect with the default config and the files
+	logger := new(log.Logger)
+	logger.SetOutput(ioutil.Discard)
+	if err := logger.ConnectSyslogTLS(ln.Addr().String(), nil,
+		log.SyslogTLSFiles(certFile, keyFile, caFile),
+		log.SyslogBackoff(time.Millisecond, 10*time.Millisecond)); err != nil {
+		t.Fatalf("error connecting to syslog: %v", err)
+	}
+	defer func() { _ = logger.DisconnectSyslog() }()
+	logger.Info("hello")
+	if client := <-clients; client != "client1" {
+		t.Errorf("expected client certificate client1, got %s", client)
+	}
+
+	// Rotate the client certificate and expect it to be used to re-dial
+	writeClientCert("client2")
+	if err := logger.Reload(); err != nil {
+		t.Fatalf("error reloading: %v", err)
+	}
+	(<-conns).Close()
+	timeout := time.After(5 * time.Second)
+	for {
+		logger.Info("hello")
+		select {
+		case client := <-clients:
+			if client != "client2" {
+				t.Errorf("expected client certificate client2, got %s", client)
+			}
+			return
+		case <-timeout:
+			t.Fatal("expected syslog connection to be re-dialed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestLoggerSyslogTLSFilesInvalid(t *testing.T) {
+	logger := new(log.Logger)
+	if err := logger.ConnectSyslogTLS("127.0.0.1:6514", nil,
+		log.SyslogTLSFiles("", "", "/nonexistent/ca.pem")); err == nil {
+		_ = logger.DisconnectSyslog()
+		t.Error("expected error loading missing CA file")
+	}
+	if err := logger.ConnectSyslog("127.0.0.1:514",
+		log.SyslogTLSFiles("", "", "/nonexistent/ca.pem")); err == nil {
+		_ = logger.DisconnectSyslog()
+		t.Error("expected error using TLS files without TLS")
+	}
+}
+
+// newCert returns a certificate for name signed by parent or, if nil,
+// self-signed as a CA.
+func newCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("error generating serial number: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	return cert, key
+}
+
+// writePEM writes a PEM block to path.
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatalf("error writing %s: %v", path, err)
+	}
+}